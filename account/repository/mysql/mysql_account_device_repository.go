@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/db"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/util"
+)
+
+func NewMySqlAccountDeviceRepository(database db.IDB) domain.IAccountDeviceRepository {
+	return &MySqlAccountDeviceRepository{
+		Db: database,
+	}
+}
+
+type MySqlAccountDeviceRepository struct {
+	Db db.IDB
+}
+
+func (dr MySqlAccountDeviceRepository) HasAccountDevices(ctx context.Context, accountID string) (bool, error) {
+	ctx, cancel := dr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("1").
+		From("account_device").
+		Where(sq.Eq{"account_id": accountID}).
+		Limit(1)
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "HAD00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	var found int
+	err = dr.Db.QueryRowContext(ctx, sqlString, args...).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "HAD01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return true, nil
+}
+
+// TouchAccountDevice stores the device, or updates when and where it was last seen when the account
+// already knows it. It reports whether the device is new.
+func (dr MySqlAccountDeviceRepository) TouchAccountDevice(ctx context.Context, device domain.AccountDevice) (bool, error) {
+	ctx, cancel := dr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if device.AccountDeviceID == "" {
+		device.AccountDeviceID = util.GenerateUUID()
+	}
+
+	query := sq.Insert("account_device").
+		Columns("account_device_id, account_id, device_hash, user_agent, last_ip, first_seen_at, last_seen_at").
+		Values(device.AccountDeviceID, device.AccountID, device.DeviceHash, device.UserAgent, device.LastIP, device.LastSeenAt, device.LastSeenAt).
+		Suffix("ON DUPLICATE KEY UPDATE last_ip = VALUES(last_ip), last_seen_at = VALUES(last_seen_at)")
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "TAD00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	result, err := dr.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "TAD01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	//mysql reports 1 affected row for an insert and 2 (or 0 when nothing changed) for an update
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "TAD02", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return affected == 1, nil
+}
+
+// DeleteAccountDevices forgets every device the account has signed in from.
+func (dr MySqlAccountDeviceRepository) DeleteAccountDevices(ctx context.Context, accountID string) error {
+	ctx, cancel := dr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Delete("account_device").
+		Where(sq.Eq{"account_id": accountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DAD00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = dr.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DAD01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
@@ -1,28 +1,32 @@
 package mysql
 
 import (
-	"database/sql"
+	"context"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/go-sql-driver/mysql"
 	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/db"
 	"github.com/pajri/personal-backend/domain"
 	"github.com/pajri/personal-backend/global"
 	"github.com/pajri/personal-backend/util"
 )
 
-func NewMySqlAccountRepository(db *sql.DB) domain.IAccountRepository {
+func NewMySqlAccountRepository(database db.IDB) domain.IAccountRepository {
 	return &MySqlUserRepository{
-		Db: db,
+		Db: database,
 	}
 }
 
 type MySqlUserRepository struct {
-	Db *sql.DB
+	Db db.IDB
 }
 
-func (ur MySqlUserRepository) GetAccount(filter domain.AccountFilter) (*domain.Account, error) {
-	query := sq.Select("account_id, password, email, salt, email_token, is_verified, password_token").
+func (ur MySqlUserRepository) GetAccount(ctx context.Context, filter domain.AccountFilter) (*domain.Account, error) {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("account_id, password, email, salt, is_verified, totp_secret, is_totp_enabled").
 		From("account")
 
 	if filter.Email != "" {
@@ -35,10 +39,10 @@ func (ur MySqlUserRepository) GetAccount(filter domain.AccountFilter) (*domain.A
 
 	sqlString, args, err := query.ToSql()
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("GA00", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GA00", err, global.FRIENDLY_MESSAGE)
 	}
 
-	row := ur.Db.QueryRow(sqlString, args...)
+	row := ur.Db.QueryRowContext(ctx, sqlString, args...)
 
 	account := new(domain.Account)
 	err = row.Scan(
@@ -46,18 +50,21 @@ func (ur MySqlUserRepository) GetAccount(filter domain.AccountFilter) (*domain.A
 		&account.Password,
 		&account.Email,
 		&account.Salt,
-		&account.EmailToken,
 		&account.IsVerified,
-		&account.PasswordToken,
+		&account.TOTPSecret,
+		&account.IsTOTPEnabled,
 	)
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("GA02", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GA02", err, global.FRIENDLY_MESSAGE)
 	}
 
 	return account, nil
 }
 
-func (ur MySqlUserRepository) InsertAccount(account domain.Account) (*domain.Account, error) {
+func (ur MySqlUserRepository) InsertAccount(ctx context.Context, account domain.Account) (*domain.Account, error) {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	if account.AccountID == "" {
 		account.AccountID = util.GenerateUUID()
 	}
@@ -68,56 +75,57 @@ func (ur MySqlUserRepository) InsertAccount(account domain.Account) (*domain.Acc
 			email, 
 			password, 
 			salt, 
-			email_token, 
-			is_verified,
-			password_token`).
+			is_verified`).
 		Values(
 			account.AccountID,
 			account.Email,
 			account.Password,
 			account.Salt,
-			account.EmailToken,
 			account.IsVerified,
-			account.PasswordToken,
 		)
 
 	sql, args, err := query.ToSql()
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("IA00", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IA00", err, global.FRIENDLY_MESSAGE)
 	}
 
-	tx, err := ur.Db.Begin()
+	tx, err := ur.Db.BeginTx(ctx)
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("IA01", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IA01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sql)
+	stmt, err := tx.PrepareContext(ctx, sql)
 	if err != nil {
 		tx.Rollback()
-		return nil, cerror.NewAndPrintWithTag("IA02", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IA02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sql, args...)
+	_, err = tx.ExecContext(ctx, sql, args...)
 	if err != nil {
 		errMySQL, ok := err.(*mysql.MySQLError)
 		if ok && errMySQL.Number == 1062 {
 			tx.Rollback()
-			return nil, cerror.NewAndPrintWithTag("IA03", err, global.FRIENDLY_DUPLICATE_EMAIL)
+			cerr := cerror.NewAndPrintWithTagContext(ctx, "IA03", err, global.FRIENDLY_DUPLICATE_EMAIL)
+			cerr.Type = cerror.TYPE_CONFLICT
+			return nil, cerr
 		}
 		tx.Rollback()
-		return nil, cerror.NewAndPrintWithTag("IA05", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IA05", err, global.FRIENDLY_MESSAGE)
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("IA04", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IA04", err, global.FRIENDLY_MESSAGE)
 	}
 
 	return &account, nil
 }
 
-func (ur MySqlUserRepository) UpdateIsVerified(accountId string, isVerified bool) error {
+func (ur MySqlUserRepository) UpdateIsVerified(ctx context.Context, accountId string, isVerified bool) error {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	/*start create query*/
 	query := sq.Update("account").
 		Set("is_verified", isVerified).
@@ -125,36 +133,39 @@ func (ur MySqlUserRepository) UpdateIsVerified(accountId string, isVerified bool
 
 	sqlString, args, err := query.ToSql()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UIV00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UIV00", err, global.FRIENDLY_MESSAGE)
 	}
 	/*start create query*/
 
-	tx, err := ur.Db.Begin()
+	tx, err := ur.Db.BeginTx(ctx)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UIV01", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UIV01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sqlString)
+	stmt, err := tx.PrepareContext(ctx, sqlString)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("UIV02", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UIV02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sqlString, args...)
+	_, err = tx.ExecContext(ctx, sqlString, args...)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("UIV03", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UIV03", err, global.FRIENDLY_MESSAGE)
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UIV04", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UIV04", err, global.FRIENDLY_MESSAGE)
 	}
 	return nil
 }
 
-func (ur MySqlUserRepository) UpdateSaltAndPassword(account domain.Account) error {
+func (ur MySqlUserRepository) UpdateSaltAndPassword(ctx context.Context, account domain.Account) error {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	/*start create query*/
 	query := sq.Update("account").
 		Set("salt", account.Salt).
@@ -163,66 +174,91 @@ func (ur MySqlUserRepository) UpdateSaltAndPassword(account domain.Account) erro
 
 	sqlString, args, err := query.ToSql()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("USP00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "USP00", err, global.FRIENDLY_MESSAGE)
 	}
 	/*start create query*/
 
-	tx, err := ur.Db.Begin()
+	tx, err := ur.Db.BeginTx(ctx)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("USP01", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "USP01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sqlString)
+	stmt, err := tx.PrepareContext(ctx, sqlString)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("USP02", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "USP02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sqlString, args...)
+	_, err = tx.ExecContext(ctx, sqlString, args...)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("USP03", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "USP03", err, global.FRIENDLY_MESSAGE)
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("USP04", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "USP04", err, global.FRIENDLY_MESSAGE)
 	}
 	return nil
 }
 
-func (ur MySqlUserRepository) UpdatePasswordToken(account domain.Account) error {
+func (ur MySqlUserRepository) UpdateTOTP(ctx context.Context, account domain.Account) error {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := sq.Update("account").
-		Set("password_token", account.PasswordToken).
+		Set("totp_secret", account.TOTPSecret).
+		Set("is_totp_enabled", account.IsTOTPEnabled).
 		Where(sq.Eq{"account_id": account.AccountID})
 
 	sqlString, args, err := query.ToSql()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UPT00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UTP00", err, global.FRIENDLY_MESSAGE)
 	}
 
-	tx, err := ur.Db.Begin()
+	tx, err := ur.Db.BeginTx(ctx)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UPT01", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UTP01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sqlString)
+	stmt, err := tx.PrepareContext(ctx, sqlString)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("UPT02", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UTP02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sqlString, args...)
+	_, err = tx.ExecContext(ctx, sqlString, args...)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("UPT03", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UTP03", err, global.FRIENDLY_MESSAGE)
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UPT04", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UTP04", err, global.FRIENDLY_MESSAGE)
 	}
 	return nil
 }
+
+// DeleteAccount removes the account row, everything that references it has to be removed first.
+func (ur MySqlUserRepository) DeleteAccount(ctx context.Context, accountID string) error {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Delete("account").
+		Where(sq.Eq{"account_id": accountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DAC00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = ur.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DAC01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
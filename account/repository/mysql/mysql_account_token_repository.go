@@ -0,0 +1,206 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/db"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/util"
+)
+
+func NewMySqlAccountTokenRepository(database db.IDB) domain.IAccountTokenRepository {
+	return &MySqlAccountTokenRepository{
+		Db: database,
+	}
+}
+
+type MySqlAccountTokenRepository struct {
+	Db db.IDB
+}
+
+// ReplaceAccountToken removes the tokens of the account with the same purpose and stores the new one
+// in the same transaction, so only the link that was sent last can be used.
+func (tr MySqlAccountTokenRepository) ReplaceAccountToken(ctx context.Context, token domain.AccountToken) error {
+	ctx, cancel := tr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if token.AccountTokenID == "" {
+		token.AccountTokenID = util.GenerateUUID()
+	}
+
+	/*start create query*/
+	deleteQuery := sq.Delete("account_token").
+		Where(sq.Eq{
+			"account_id": token.AccountID,
+			"purpose":    token.Purpose,
+		})
+
+	deleteSql, deleteArgs, err := deleteQuery.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "RAT00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	insertQuery := sq.Insert("account_token").
+		Columns("account_token_id, account_id, purpose, token_hash, expires_at, created_at").
+		Values(token.AccountTokenID, token.AccountID, token.Purpose, token.TokenHash, token.ExpiresAt, time.Now())
+
+	insertSql, insertArgs, err := insertQuery.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "RAT01", err, global.FRIENDLY_MESSAGE)
+	}
+	/*end create query*/
+
+	tx, err := tr.Db.BeginTx(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "RAT02", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = tx.ExecContext(ctx, deleteSql, deleteArgs...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "RAT03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = tx.ExecContext(ctx, insertSql, insertArgs...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "RAT04", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "RAT05", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+func (tr MySqlAccountTokenRepository) GetAccountToken(ctx context.Context, purpose, tokenHash string) (*domain.AccountToken, error) {
+	ctx, cancel := tr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("account_token_id, account_id, purpose, token_hash, expires_at").
+		From("account_token").
+		Where(sq.Eq{
+			"purpose":    purpose,
+			"token_hash": tokenHash,
+		})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GAT00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	token := new(domain.AccountToken)
+	err = tr.Db.QueryRowContext(ctx, sqlString, args...).Scan(
+		&token.AccountTokenID,
+		&token.AccountID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "GAT01", err, global.FRIENDLY_INVALID_TOKEN)
+		cerr.Type = cerror.TYPE_NOT_FOUND
+		return nil, cerr
+	}
+
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GAT02", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return token, nil
+}
+
+// ConsumeAccountToken looks the token up and deletes it in one transaction. The row is locked
+// while it is read, so two concurrent requests with the same token cannot both get it.
+// Expired tokens are deleted and returned too, checking ExpiresAt is up to the caller.
+func (tr MySqlAccountTokenRepository) ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (*domain.AccountToken, error) {
+	ctx, cancel := tr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	/*start create query*/
+	selectQuery := sq.Select("account_token_id, account_id, purpose, token_hash, expires_at").
+		From("account_token").
+		Where(sq.Eq{
+			"purpose":    purpose,
+			"token_hash": tokenHash,
+		}).
+		Suffix("FOR UPDATE")
+
+	selectSql, selectArgs, err := selectQuery.ToSql()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CAT00", err, global.FRIENDLY_MESSAGE)
+	}
+	/*end create query*/
+
+	tx, err := tr.Db.BeginTx(ctx)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CAT01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	token := new(domain.AccountToken)
+	err = tx.QueryRowContext(ctx, selectSql, selectArgs...).Scan(
+		&token.AccountTokenID,
+		&token.AccountID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+	)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			cerr := cerror.NewAndPrintWithTagContext(ctx, "CAT02", err, global.FRIENDLY_INVALID_TOKEN)
+			cerr.Type = cerror.TYPE_NOT_FOUND
+			return nil, cerr
+		}
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CAT03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	deleteQuery := sq.Delete("account_token").
+		Where(sq.Eq{"account_token_id": token.AccountTokenID})
+
+	deleteSql, deleteArgs, err := deleteQuery.ToSql()
+	if err != nil {
+		tx.Rollback()
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CAT04", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = tx.ExecContext(ctx, deleteSql, deleteArgs...)
+	if err != nil {
+		tx.Rollback()
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CAT05", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CAT06", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return token, nil
+}
+
+// DeleteAccountTokens removes the tokens of every purpose of the account.
+func (tr MySqlAccountTokenRepository) DeleteAccountTokens(ctx context.Context, accountID string) error {
+	ctx, cancel := tr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Delete("account_token").
+		Where(sq.Eq{"account_id": accountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DAT00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = tr.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DAT01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
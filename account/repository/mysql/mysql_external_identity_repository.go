@@ -0,0 +1,135 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/db"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/util"
+)
+
+func NewMySqlExternalIdentityRepository(database db.IDB) domain.IExternalIdentityRepository {
+	return &MySqlExternalIdentityRepository{
+		Db: database,
+	}
+}
+
+type MySqlExternalIdentityRepository struct {
+	Db db.IDB
+}
+
+func (er MySqlExternalIdentityRepository) GetExternalIdentity(ctx context.Context, filter domain.ExternalIdentityFilter) (*domain.ExternalIdentity, error) {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("external_identity_id, account_id, provider, subject, email, created_at").
+		From("external_identity")
+
+	if filter.AccountID != "" {
+		query = query.Where(sq.Eq{"account_id": filter.AccountID})
+	}
+
+	if filter.Provider != "" {
+		query = query.Where(sq.Eq{"provider": filter.Provider})
+	}
+
+	if filter.Subject != "" {
+		query = query.Where(sq.Eq{"subject": filter.Subject})
+	}
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GEI00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	row := er.Db.QueryRowContext(ctx, sqlString, args...)
+
+	identity := new(domain.ExternalIdentity)
+	err = row.Scan(
+		&identity.ExternalIdentityID,
+		&identity.AccountID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GEI01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return identity, nil
+}
+
+func (er MySqlExternalIdentityRepository) InsertExternalIdentity(ctx context.Context, identity domain.ExternalIdentity) error {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if identity.ExternalIdentityID == "" {
+		identity.ExternalIdentityID = util.GenerateUUID()
+	}
+
+	query := sq.Insert("external_identity").
+		Columns("external_identity_id, account_id, provider, subject, email, created_at").
+		Values(
+			identity.ExternalIdentityID,
+			identity.AccountID,
+			identity.Provider,
+			identity.Subject,
+			identity.Email,
+			time.Now(),
+		)
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "IEI00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	tx, err := er.Db.BeginTx(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "IEI01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, sqlString)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "IEI02", err, global.FRIENDLY_MESSAGE)
+	}
+	defer stmt.Close()
+
+	_, err = tx.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "IEI03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "IEI04", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+// DeleteExternalIdentities unlinks every provider from the account.
+func (er MySqlExternalIdentityRepository) DeleteExternalIdentities(ctx context.Context, accountID string) error {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Delete("external_identity").
+		Where(sq.Eq{"account_id": accountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DEI00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = er.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DEI01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
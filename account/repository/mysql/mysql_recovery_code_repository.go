@@ -0,0 +1,152 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/db"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/util"
+)
+
+func NewMySqlRecoveryCodeRepository(database db.IDB) domain.IRecoveryCodeRepository {
+	return &MySqlRecoveryCodeRepository{
+		Db: database,
+	}
+}
+
+type MySqlRecoveryCodeRepository struct {
+	Db db.IDB
+}
+
+// ReplaceRecoveryCodes removes every recovery code of the account and stores the new ones
+// in the same transaction, so an account never ends up with two generations of codes.
+func (rr MySqlRecoveryCodeRepository) ReplaceRecoveryCodes(ctx context.Context, accountID string, codes []domain.RecoveryCode) error {
+	ctx, cancel := rr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	/*start create query*/
+	deleteQuery := sq.Delete("recovery_code").
+		Where(sq.Eq{"account_id": accountID})
+
+	deleteSql, deleteArgs, err := deleteQuery.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "RRC00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	insertQuery := sq.Insert("recovery_code").
+		Columns("recovery_code_id, account_id, code_hash, is_used")
+	for _, code := range codes {
+		if code.RecoveryCodeID == "" {
+			code.RecoveryCodeID = util.GenerateUUID()
+		}
+		insertQuery = insertQuery.Values(code.RecoveryCodeID, accountID, code.CodeHash, false)
+	}
+
+	insertSql, insertArgs, err := insertQuery.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "RRC01", err, global.FRIENDLY_MESSAGE)
+	}
+	/*end create query*/
+
+	tx, err := rr.Db.BeginTx(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "RRC02", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = tx.ExecContext(ctx, deleteSql, deleteArgs...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "RRC03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = tx.ExecContext(ctx, insertSql, insertArgs...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "RRC04", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "RRC05", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused code as used. The update is conditional on is_used,
+// so two concurrent requests with the same code cannot both succeed.
+func (rr MySqlRecoveryCodeRepository) UseRecoveryCode(ctx context.Context, accountID, codeHash string) error {
+	ctx, cancel := rr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Update("recovery_code").
+		Set("is_used", true).
+		Where(sq.Eq{
+			"account_id": accountID,
+			"code_hash":  codeHash,
+			"is_used":    false,
+		})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "URC00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	result, err := rr.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "URC01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "URC02", err, global.FRIENDLY_MESSAGE)
+	}
+
+	if affected == 0 {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "URC03", sql.ErrNoRows, global.FRIENDLY_INVALID_OTP)
+		cerr.Type = cerror.TYPE_NOT_FOUND
+		return cerr
+	}
+
+	return nil
+}
+
+func (rr MySqlRecoveryCodeRepository) DeleteRecoveryCodes(ctx context.Context, accountID string) error {
+	ctx, cancel := rr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Delete("recovery_code").
+		Where(sq.Eq{"account_id": accountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DRC00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	tx, err := rr.Db.BeginTx(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DRC01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, sqlString)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "DRC02", err, global.FRIENDLY_MESSAGE)
+	}
+	defer stmt.Close()
+
+	_, err = tx.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "DRC03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DRC04", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
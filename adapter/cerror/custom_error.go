@@ -1,12 +1,20 @@
 package cerror
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+
+	"github.com/pajri/personal-backend/adapter/logger"
+)
 
 const (
-	TYPE_UNDEFINED    = 0
-	TYPE_NOT_FOUND    = 1
-	TYPE_UNAUTHORIZED = 2
-	TYPE_EXPIRED      = 3
+	TYPE_UNDEFINED         = 0
+	TYPE_NOT_FOUND         = 1
+	TYPE_UNAUTHORIZED      = 2
+	TYPE_EXPIRED           = 3
+	TYPE_BAD_REQUEST       = 4
+	TYPE_TOO_MANY_REQUESTS = 5
+	TYPE_CONFLICT          = 6
 )
 
 type Error struct {
@@ -14,14 +22,21 @@ type Error struct {
 	Err             error
 	FriendlyMessage string
 	Type            int
+	Details         []string
 }
 
 func (e Error) Error() string {
 	return e.Err.Error()
 }
 
+// PrintErrorWithTag logs the error with its tag, for code that runs outside a request.
 func (e Error) PrintErrorWithTag() {
-	fmt.Printf("[%s] %s ", e.Tag, e.Err)
+	e.PrintErrorWithTagContext(context.Background())
+}
+
+// PrintErrorWithTagContext logs the error with its tag and the request id in ctx.
+func (e Error) PrintErrorWithTagContext(ctx context.Context) {
+	logger.WithTag(ctx, e.Tag).Error(e.Err)
 }
 
 func (e Error) FriendlyMessageWithTag() string {
@@ -29,11 +44,19 @@ func (e Error) FriendlyMessageWithTag() string {
 }
 
 func New(tag string, err error, friendly string) error {
-	return &Error{tag, err, friendly, TYPE_UNDEFINED}
+	return &Error{Tag: tag, Err: err, FriendlyMessage: friendly, Type: TYPE_UNDEFINED}
 }
 
 func NewAndPrintWithTag(tag string, err error, friendly string) Error {
-	cerr := Error{tag, err, friendly, TYPE_UNDEFINED}
+	cerr := Error{Tag: tag, Err: err, FriendlyMessage: friendly, Type: TYPE_UNDEFINED}
 	cerr.PrintErrorWithTag()
 	return cerr
 }
+
+// NewAndPrintWithTagContext is NewAndPrintWithTag for code that runs within a request, the line
+// it logs has the request id in ctx.
+func NewAndPrintWithTagContext(ctx context.Context, tag string, err error, friendly string) Error {
+	cerr := Error{Tag: tag, Err: err, FriendlyMessage: friendly, Type: TYPE_UNDEFINED}
+	cerr.PrintErrorWithTagContext(ctx)
+	return cerr
+}
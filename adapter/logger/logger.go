@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/global"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_TEXT = "text"
+
+	REQUEST_ID_FIELD = "request_id"
+	TRACE_ID_FIELD   = "trace_id"
+	TAG_FIELD        = "tag"
+)
+
+type requestIDKey struct{}
+
+// log writes to stderr at info level until Init is called, every line goes through the
+// redaction hook.
+var log = newLogger()
+
+func newLogger() *logrus.Logger {
+	l := logrus.New()
+	l.AddHook(redactHook{})
+	return l
+}
+
+// Init sets the level and format of the config. It is called once while the process starts,
+// before requests are served.
+func Init(logConfig config.LogConfig) error {
+	level := logrus.InfoLevel
+	if logConfig.Level != "" {
+		var err error
+		level, err = logrus.ParseLevel(logConfig.Level)
+		if err != nil {
+			return fmt.Errorf("invalid log level : %w", err)
+		}
+	}
+	log.SetLevel(level)
+
+	format := logConfig.Format
+	if format == "" {
+		format = FORMAT_JSON
+		if global.IsEnvDevelopment() {
+			format = FORMAT_TEXT
+		}
+	}
+
+	switch format {
+	case FORMAT_JSON:
+		log.SetFormatter(&logrus.JSONFormatter{})
+	case FORMAT_TEXT:
+		log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	default:
+		return fmt.Errorf("log format %s is not supported", format)
+	}
+
+	return nil
+}
+
+// Entry returns the logger for code that runs outside a request.
+func Entry() *logrus.Entry {
+	return logrus.NewEntry(log)
+}
+
+// FromContext returns the logger of a request, every line it writes has the request id and the
+// trace id when the request is traced.
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := Entry()
+	if requestID := RequestID(ctx); requestID != "" {
+		entry = entry.WithField(REQUEST_ID_FIELD, requestID)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		entry = entry.WithField(TRACE_ID_FIELD, spanContext.TraceID().String())
+	}
+	return entry
+}
+
+// WithTag returns the logger of the request in ctx with the tag that tells where the line was
+// written, the same tags cerror uses.
+func WithTag(ctx context.Context, tag string) *logrus.Entry {
+	return FromContext(ctx).WithField(TAG_FIELD, tag)
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
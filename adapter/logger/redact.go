@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const REDACTED = "REDACTED"
+
+// SENSITIVE_FIELDS are parts of field names whose values are never written.
+var SENSITIVE_FIELDS = []string{"password", "secret", "token", "authorization", "cookie"}
+
+var (
+	jwtPattern        = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern     = regexp.MustCompile(`(?i)(bearer\s+)\S+`)
+	queryParamPattern = regexp.MustCompile(`(?i)\b((?:[a-z_]*token|code|state|password|secret)=)[^&\s"']+`)
+	emailPattern      = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+)
+
+// Redact hides tokens and passwords in s and masks email addresses, john@example.com becomes
+// j***@example.com so lines of one account can still be told apart from others.
+func Redact(s string) string {
+	s = jwtPattern.ReplaceAllString(s, REDACTED)
+	s = bearerPattern.ReplaceAllString(s, "${1}"+REDACTED)
+	s = queryParamPattern.ReplaceAllString(s, "${1}"+REDACTED)
+	s = emailPattern.ReplaceAllString(s, "${1}***@${2}")
+	return s
+}
+
+// redactHook runs Redact over the message and the fields of every line, and drops the values of
+// fields named like SENSITIVE_FIELDS.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Redact(entry.Message)
+
+	for key, value := range entry.Data {
+		if isSensitiveField(key) {
+			entry.Data[key] = REDACTED
+			continue
+		}
+
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = Redact(v)
+		case error:
+			entry.Data[key] = Redact(v.Error())
+		case []string:
+			redacted := make([]string, len(v))
+			for i, s := range v {
+				redacted[i] = Redact(s)
+			}
+			entry.Data[key] = redacted
+		}
+	}
+
+	return nil
+}
+
+func isSensitiveField(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range SENSITIVE_FIELDS {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/pajri/personal-backend/adapter/logger"
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/db"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/helper"
+	"github.com/pajri/personal-backend/metrics"
+	"github.com/pajri/personal-backend/middleware"
+	"github.com/pajri/personal-backend/tracing"
+
+	_postDelivery "github.com/pajri/personal-backend/post/delivery"
+	_postRepository "github.com/pajri/personal-backend/post/repository/mysql"
+	_postUsecase "github.com/pajri/personal-backend/post/usecase"
+
+	_accountRepository "github.com/pajri/personal-backend/account/repository/mysql"
+	_authDelivery "github.com/pajri/personal-backend/auth/delivery"
+	_authUsecase "github.com/pajri/personal-backend/auth/usecase"
+
+	_profileDelivery "github.com/pajri/personal-backend/profile/delivery"
+	_profileRepository "github.com/pajri/personal-backend/profile/repository/mysql"
+	_profileUsecase "github.com/pajri/personal-backend/profile/usecase"
+
+	_imageDelivery "github.com/pajri/personal-backend/image/delivery"
+	_imageRepository "github.com/pajri/personal-backend/image/repository/mysql"
+	_imageUsecase "github.com/pajri/personal-backend/image/usecase"
+
+	_emailRepository "github.com/pajri/personal-backend/email/repository/mysql"
+	_emailUsecase "github.com/pajri/personal-backend/email/usecase"
+
+	_healthDelivery "github.com/pajri/personal-backend/health/delivery"
+	_healthUsecase "github.com/pajri/personal-backend/health/usecase"
+)
+
+// App is built from one config and hands it to every component through their constructors,
+// nothing reads the config afterwards. Apps with different configs can live in one process,
+// which lets tests build the whole router on their own connections.
+type App struct {
+	Config config.Configuration
+	DB     *sql.DB
+	Redis  helper.IRedis
+
+	Metrics *metrics.Metrics
+	Tracing *tracing.Tracing
+
+	JWTHelper    helper.JWTHelper
+	CookieHelper helper.CookieHelper
+
+	EmailUsecase   domain.IEmailUsecase
+	ImageUsecase   domain.IImageUsecase
+	PostUsecase    domain.IPostUsecase
+	ProfileUsecase domain.IProfileUsecase
+	AuthUsecase    domain.IAuthUsecase
+	HealthUsecase  domain.IHealthUsecase
+}
+
+// OpenDB connects to the database and checks that it answers.
+func OpenDB(dbConfig config.DBConfig) (*sql.DB, error) {
+	dbConn, err := db.InitDB(dbConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to db : %w", err)
+	}
+
+	err = dbConn.Ping()
+	if err != nil {
+		dbConn.Close()
+		return nil, fmt.Errorf("error while pinging db : %w", err)
+	}
+
+	return dbConn, nil
+}
+
+// New connects to the database and redis of the config, applies pending migrations when
+// DB.AutoMigrate is set, and builds the app on them. Redis calls are timed by the metrics of
+// the app.
+func New(cfg config.Configuration) (*App, error) {
+	/* start init db*/
+	dbConn, err := OpenDB(cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DB.AutoMigrate {
+		err = autoMigrate(dbConn)
+		if err != nil {
+			dbConn.Close()
+			return nil, fmt.Errorf("unable to migrate db : %w", err)
+		}
+	}
+	/*end init db*/
+
+	appTracing, err := tracing.New(cfg.Tracing)
+	if err != nil {
+		dbConn.Close()
+		return nil, err
+	}
+
+	/*start init redis*/
+	appMetrics := metrics.New(dbConn)
+	redisHelper, err := helper.NewRedisHelper(cfg.Redis, appMetrics, appTracing.Tracer())
+	if err != nil {
+		dbConn.Close()
+		return nil, fmt.Errorf("unable to connect to redis : %w", err)
+	}
+	/*end init redis*/
+
+	a, err := newApp(cfg, dbConn, redisHelper, appMetrics, appTracing)
+	if err != nil {
+		redisHelper.Close()
+		dbConn.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// NewWithConnections builds the app on a database and redis that are already open. The app
+// takes them over, Close closes them.
+func NewWithConnections(cfg config.Configuration, dbConn *sql.DB, redisHelper helper.IRedis) (*App, error) {
+	appTracing, err := tracing.New(cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+
+	return newApp(cfg, dbConn, redisHelper, metrics.New(dbConn), appTracing)
+}
+
+func newApp(cfg config.Configuration, dbConn *sql.DB, redisHelper helper.IRedis, appMetrics *metrics.Metrics, appTracing *tracing.Tracing) (*App, error) {
+	a := &App{Config: cfg, DB: dbConn, Redis: redisHelper, Metrics: appMetrics, Tracing: appTracing}
+	tracer := appTracing.Tracer()
+
+	//setup helper
+	var err error
+	a.JWTHelper, err = helper.NewJWTHelper(cfg.JWT, cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+	a.CookieHelper = helper.NewCookieHelper(cfg.Host)
+
+	emailTemplates, err := helper.NewEmailTemplates(global.DEFAULT_LOCALE)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load email templates : %w", err)
+	}
+	mailSender, err := helper.NewMailSender(cfg.Mail, cfg.SMTP)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up mail transport : %w", err)
+	}
+	oidcHelper := helper.NewOIDCHelper(cfg.OIDC)
+	passwordHasher := helper.NewPasswordHasher(cfg.PasswordHash)
+	passwordPolicy, err := helper.NewPasswordPolicy(cfg.PasswordPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load password policy : %w", err)
+	}
+
+	//setup repo and usecase
+	database := db.New(dbConn, time.Duration(cfg.DB.QueryTimeoutSeconds)*time.Second, tracer)
+	transactor := db.NewTransactor(dbConn, tracer)
+
+	emailRepo := _emailRepository.NewMySqlEmailRepository(database)
+	a.EmailUsecase = _emailUsecase.NewEmailUsecase(emailRepo, emailTemplates, mailSender, cfg.EmailQueue, a.Metrics, tracer)
+
+	imageRepo := _imageRepository.NewMySqlImageRepository(database)
+	postRepo := _postRepository.NewMySqlPostRepository(database)
+	a.ImageUsecase = _imageUsecase.NewImageUsecase(imageRepo, postRepo, a.Metrics)
+	a.PostUsecase = _postUsecase.NewPostUseCase(postRepo, imageRepo, transactor)
+
+	accountRepo := _accountRepository.NewMySqlAccountRepository(database)
+	recoveryCodeRepo := _accountRepository.NewMySqlRecoveryCodeRepository(database)
+	accountTokenRepo := _accountRepository.NewMySqlAccountTokenRepository(database)
+	accountDeviceRepo := _accountRepository.NewMySqlAccountDeviceRepository(database)
+	externalIdentityRepo := _accountRepository.NewMySqlExternalIdentityRepository(database)
+
+	profileRepo := _profileRepository.NewMySqlProfileRepository(database)
+	a.ProfileUsecase = _profileUsecase.NewProfileUsecase(accountRepo, profileRepo)
+
+	authConfig := _authUsecase.AuthConfig{
+		FEHost:                cfg.FEHost,
+		TOTPIssuer:            cfg.TOTP.Issuer,
+		RecoveryCodeSecret:    cfg.TOTP.RecoveryCodeSecret,
+		ResendCooldownSeconds: cfg.EmailVerification.ResendCooldownSeconds,
+	}
+	a.AuthUsecase = _authUsecase.NewAuthUsecase(accountRepo, profileRepo, recoveryCodeRepo, accountTokenRepo, accountDeviceRepo, externalIdentityRepo, postRepo, imageRepo, transactor, a.EmailUsecase, oidcHelper, passwordHasher, passwordPolicy, redisHelper, a.JWTHelper, authConfig, a.Metrics)
+
+	a.HealthUsecase = _healthUsecase.NewHealthUsecase(dbConn, redisHelper, _imageUsecase.IMAGE_DIR)
+
+	return a, nil
+}
+
+// Router builds the gin engine with every handler.
+func (a *App) Router() *gin.Engine {
+	r := gin.New()
+	r.Use(middleware.RequestID(), a.Tracing.Middleware(), middleware.AccessLog(), gin.Recovery())
+	r.Use(a.Metrics.Middleware(), middleware.BodyLimit())
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{a.Config.FEHost},
+		AllowMethods:     []string{"GET", "POST"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{middleware.REQUEST_ID_HEADER},
+	}))
+	r.Static("/upload/images/", "./upload/images")
+
+	r.Use(middleware.Middleware(a.AuthUsecase, a.JWTHelper, a.Redis))
+	_postDelivery.NewPostHandler(r, a.PostUsecase)
+	_authDelivery.NewAuthHandler(r, a.AuthUsecase, a.JWTHelper, a.CookieHelper, a.Config.FEHost)
+	_imageDelivery.NewImageHandler(r, a.ImageUsecase)
+	_profileDelivery.NewProfileHandler(r, a.ProfileUsecase)
+	_healthDelivery.NewHealthHandler(r, a.HealthUsecase)
+
+	return r
+}
+
+// Close exports the spans that are still buffered and closes the connections.
+func (a *App) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), tracing.SHUTDOWN_TIMEOUT)
+	defer cancel()
+	err := a.Tracing.Shutdown(ctx)
+	if err != nil {
+		logger.Entry().WithError(err).Warn("unable to export the last spans")
+	}
+
+	a.Redis.Close()
+	a.DB.Close()
+}
+
+// autoMigrate applies pending migrations while the app starts.
+func autoMigrate(dbConn *sql.DB) error {
+	migrator, err := db.NewMigrator(dbConn)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := migrator.Up(context.Background())
+	for _, migration := range migrations {
+		logger.Entry().Infof("applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package app_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pajri/personal-backend/app"
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/helper"
+	"github.com/pajri/personal-backend/middleware"
+)
+
+const (
+	testHost   = "http://localhost:5000"
+	testFEHost = "http://localhost:8080"
+)
+
+// stubRedis answers Ping and finds no key, which is all the requests below need.
+type stubRedis struct {
+	helper.IRedis
+}
+
+func (r stubRedis) Get(ctx context.Context, key string) (string, error) {
+	return "", errors.New("key not found")
+}
+
+func (r stubRedis) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r stubRedis) Close() error {
+	return nil
+}
+
+// newTestRouter builds the whole app on a database that is never reached, the requests below
+// are answered before a query.
+func newTestRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Configuration{
+		Host:   testHost,
+		FEHost: testFEHost,
+		Mail:   config.MailConfig{Transport: "noop"},
+		JWT:    config.JWTConfig{Secret: "test-secret"},
+		TOTP:   config.TOTPConfig{Issuer: "MyMoment", RecoveryCodeSecret: "test-recovery-secret"},
+		OIDC: map[string]config.OIDCProviderConfig{
+			"mock": {Issuer: "http://localhost:1/default", ClientID: "mymoment", RedirectURL: testHost + "/api/auth/oidc/mock/callback"},
+		},
+	}
+
+	dbConn, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:1)/personal?parseTime=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := app.NewWithConnections(cfg, dbConn, stubRedis{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Close)
+
+	return a.Router()
+}
+
+func serve(router *gin.Engine, method, target string, header http.Header) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestHealthz(t *testing.T) {
+	router := newTestRouter(t)
+
+	recorder := serve(router, http.MethodGet, "/healthz", http.Header{middleware.REQUEST_ID_HEADER: {"test-request"}})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	if err != nil || body.Status != "ok" {
+		t.Fatalf("body %s, want status ok", recorder.Body.String())
+	}
+
+	if requestID := recorder.Header().Get(middleware.REQUEST_ID_HEADER); requestID != "test-request" {
+		t.Fatalf("request id %q, want the one sent", requestID)
+	}
+}
+
+func TestProtectedRouteWithoutValidToken(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantErrorType string
+	}{
+		{name: "no token", wantErrorType: "unauthorized"},
+		{name: "malformed token", authorization: "not-a-jwt", wantErrorType: "token_invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.authorization != "" {
+				header.Set("Authorization", tt.authorization)
+			}
+
+			recorder := serve(router, http.MethodGet, "/api/profile", header)
+			if recorder.Code != http.StatusUnauthorized {
+				t.Fatalf("status %d, want %d", recorder.Code, http.StatusUnauthorized)
+			}
+
+			var body middleware.AuthResponse
+			err := json.Unmarshal(recorder.Body.Bytes(), &body)
+			if err != nil || body.ErrorType != tt.wantErrorType {
+				t.Fatalf("body %s, want error type %s", recorder.Body.String(), tt.wantErrorType)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackWithoutStateCookie(t *testing.T) {
+	router := newTestRouter(t)
+
+	recorder := serve(router, http.MethodGet, "/api/auth/oidc/mock/callback?state=forged&code=code", nil)
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("status %d, want %d", recorder.Code, http.StatusFound)
+	}
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(location.String(), testFEHost+"/oauth_callback") || !strings.Contains(location.Query().Get("error"), "OCH02") {
+		t.Fatalf("redirected to %s, want the frontend with error OCH02", location)
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	DEFAULT_READ_TIMEOUT     = 60 * time.Second
+	DEFAULT_WRITE_TIMEOUT    = 60 * time.Second
+	DEFAULT_IDLE_TIMEOUT     = 120 * time.Second
+	DEFAULT_SHUTDOWN_TIMEOUT = 30 * time.Second
+)
+
+// HTTPServer returns the server for Router with the timeouts of Server in the config. The read
+// timeout covers the whole request, so it has to leave room for image uploads on slow connections.
+func (a *App) HTTPServer() *http.Server {
+	serverConfig := a.Config.Server
+	server := &http.Server{
+		Addr:           serverConfig.Addr,
+		Handler:        a.Router(),
+		ReadTimeout:    time.Duration(serverConfig.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout:   time.Duration(serverConfig.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:    time.Duration(serverConfig.IdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes: serverConfig.MaxHeaderBytes,
+	}
+
+	if server.ReadTimeout == 0 {
+		server.ReadTimeout = DEFAULT_READ_TIMEOUT
+	}
+
+	if server.WriteTimeout == 0 {
+		server.WriteTimeout = DEFAULT_WRITE_TIMEOUT
+	}
+
+	if server.IdleTimeout == 0 {
+		server.IdleTimeout = DEFAULT_IDLE_TIMEOUT
+	}
+
+	//0 leaves http.DefaultMaxHeaderBytes
+	return server
+}
+
+// MetricsServer returns the server of /metrics on Metrics.Addr of the config, or nil when it is
+// not set. It is not part of Router, so the metrics are never reachable on the API address.
+func (a *App) MetricsServer() *http.Server {
+	if a.Config.Metrics.Addr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", a.Metrics.Handler())
+
+	return &http.Server{
+		Addr:         a.Config.Metrics.Addr,
+		Handler:      mux,
+		ReadTimeout:  DEFAULT_READ_TIMEOUT,
+		WriteTimeout: DEFAULT_WRITE_TIMEOUT,
+		IdleTimeout:  DEFAULT_IDLE_TIMEOUT,
+	}
+}
+
+// ShutdownTimeout is how long requests in flight and the background workers get to finish once
+// the server is asked to stop.
+func (a *App) ShutdownTimeout() time.Duration {
+	timeout := time.Duration(a.Config.Server.ShutdownTimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = DEFAULT_SHUTDOWN_TIMEOUT
+	}
+	return timeout
+}
@@ -1,12 +1,18 @@
 package delivery
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
+	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator"
@@ -17,6 +23,16 @@ import (
 	"github.com/pajri/personal-backend/helper"
 )
 
+// MAX_LOCALE_LENGTH is the size of profile.locale
+const MAX_LOCALE_LENGTH = 10
+
+const (
+	//OIDC_STATE_COOKIE binds the login state to the browser that started it
+	OIDC_STATE_COOKIE      = "oidc_state"
+	OIDC_STATE_COOKIE_PATH = "/api/auth/oidc/"
+	OIDC_STATE_COOKIE_TTL  = 10 * time.Minute
+)
+
 // #region type helper
 type LoginRequest struct {
 	Email    string `form:"email" binding:"required"`
@@ -24,14 +40,61 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Message     []string `json:"message"`
-	AccessToken string   `json:"access_token"`
+	Message           []string `json:"message"`
+	AccessToken       string   `json:"access_token"`
+	TwoFactorRequired bool     `json:"two_factor_required"`
+	ChallengeToken    string   `json:"challenge_token,omitempty"`
+}
+
+type LoginTOTPRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkResponse struct {
+	Message []string `json:"message"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type EnrollTOTPResponse struct {
+	Message []string `json:"message"`
+	Secret  string   `json:"secret"`
+	URI     string   `json:"uri"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type ConfirmTOTPResponse struct {
+	Message       []string `json:"message"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableTOTPResponse struct {
+	Message []string `json:"message"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"`
+}
+
+type DeleteAccountResponse struct {
+	Message []string `json:"message"`
 }
 
 type SignUpRequest struct {
 	Fullname        string `form:"full_name" binding:"required"`
 	Email           string `form:"email" binding:"required"`
-	Password        string `form:"password" binding:"required,min=10"`
+	Password        string `form:"password" binding:"required"`
 	ConfirmPassword string `form:"confirm_password" binding:"required,eqfield=Password"`
 }
 
@@ -52,6 +115,14 @@ type VerifyResponse struct {
 	Message string `json:"message"`
 }
 
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResendVerificationResponse struct {
+	Message []string `json:"message"`
+}
+
 type ResetPasswordRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
@@ -77,21 +148,38 @@ type SignOutResponse struct {
 // #endregion
 
 type AuthHandler struct {
-	useCase domain.IAuthUsecase
+	useCase      domain.IAuthUsecase
+	jwtHelper    helper.JWTHelper
+	cookieHelper helper.CookieHelper
+	feHost       string
 }
 
-func NewAuthHandler(router *gin.Engine, authUsecase domain.IAuthUsecase) {
+func NewAuthHandler(router *gin.Engine, authUsecase domain.IAuthUsecase, jwtHelper helper.JWTHelper, cookieHelper helper.CookieHelper, feHost string) {
 	handler := &AuthHandler{
-		useCase: authUsecase,
+		useCase:      authUsecase,
+		jwtHelper:    jwtHelper,
+		cookieHelper: cookieHelper,
+		feHost:       feHost,
 	}
 
 	router.POST("/api/auth/login", handler.Login)
+	router.POST("/api/auth/login/totp", handler.LoginTOTP)
+	router.POST("/api/auth/magic_link", handler.SendMagicLink)
+	router.POST("/api/auth/magic_link/verify", handler.LoginMagicLink)
+	router.GET("/api/auth/oidc/:provider/login", handler.OIDCLogin)
+	router.GET("/api/auth/oidc/:provider/callback", handler.OIDCCallback)
 	router.POST("/api/auth/signup", handler.SignUp)
 	router.POST("/api/auth/refresh_token", handler.RefreshToken)
 	router.GET("/api/auth/verify_email", handler.VerifyEmail)
+	router.POST("/api/auth/resend_verification", handler.ResendVerification)
 	router.POST("/api/auth/reset_password/", handler.ResetPassword)
 	router.POST("/api/auth/change_password", handler.ChangePassword)
 	router.POST("/api/auth/signout", handler.SignOut)
+	router.POST("/api/auth/totp/enroll", handler.EnrollTOTP)
+	router.POST("/api/auth/totp/confirm", handler.ConfirmTOTP)
+	router.POST("/api/auth/totp/disable", handler.DisableTOTP)
+	router.POST("/api/auth/delete_account", handler.DeleteAccount)
+	router.GET("/.well-known/jwks.json", handler.JWKS)
 }
 
 func (ah AuthHandler) Login(c *gin.Context) {
@@ -102,7 +190,7 @@ func (ah AuthHandler) Login(c *gin.Context) {
 
 	err := c.ShouldBind(&request)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("ALG", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "ALG", err, global.FRIENDLY_MESSAGE)
 
 		/*start validation*/
 		valError := err.(validator.ValidationErrors)
@@ -138,7 +226,7 @@ func (ah AuthHandler) Login(c *gin.Context) {
 	account.Email = request.Email
 	account.Password = request.Password
 
-	token, err := ah.useCase.Login(account)
+	token, challenge, err := ah.useCase.Login(c.Request.Context(), account, ah.clientInfo(c))
 	if err != nil {
 		response := LoginResponse{
 			Message: []string{err.(cerror.Error).FriendlyMessageWithTag()},
@@ -156,16 +244,291 @@ func (ah AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	//two-factor authentication is enabled, the client has to send the code to /api/auth/login/totp
+	if challenge != nil {
+		response.TwoFactorRequired = true
+		response.ChallengeToken = challenge.ChallengeToken
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
 	response.AccessToken = token.AccessToken
 
-	cookieHelper := helper.CookieHelper{}
-	cookie := cookieHelper.SetHttpOnlyCookie("refresh_token", token.RefreshToken, token.RefreshTokenExpTime)
+	cookie := ah.cookieHelper.SetHttpOnlyCookie("refresh_token", token.RefreshToken, token.RefreshTokenExpTime)
 	http.SetCookie(c.Writer, cookie)
 
 	c.JSON(http.StatusOK, response)
 	return
 }
 
+func (ah AuthHandler) LoginTOTP(c *gin.Context) {
+	var (
+		request  LoginTOTPRequest
+		response LoginResponse
+	)
+
+	err := c.ShouldBind(&request)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "LTH00", err, global.FRIENDLY_MESSAGE)
+
+		/*start validation*/
+		valError, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, elem := range valError {
+				fieldName := elem.Field()
+				field, _ := reflect.TypeOf(&request).Elem().FieldByName(fieldName)
+				jsonField, _ := field.Tag.Lookup("json")
+
+				switch elem.Tag() {
+				case "required":
+					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
+					response.Message = append(response.Message, msg)
+					break
+				}
+			}
+
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		/*end validation*/
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	token, err := ah.useCase.LoginTOTP(c.Request.Context(), request.ChallengeToken, request.Code, ah.clientInfo(c))
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "LTH01", err, global.FRIENDLY_MESSAGE)
+		}
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+
+		httpStatus := http.StatusInternalServerError
+		if cerr.Type == cerror.TYPE_UNAUTHORIZED || cerr.Type == cerror.TYPE_EXPIRED {
+			httpStatus = http.StatusUnauthorized
+		}
+
+		c.JSON(httpStatus, response)
+		return
+	}
+
+	response.AccessToken = token.AccessToken
+
+	cookie := ah.cookieHelper.SetHttpOnlyCookie("refresh_token", token.RefreshToken, token.RefreshTokenExpTime)
+	http.SetCookie(c.Writer, cookie)
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+func (ah AuthHandler) SendMagicLink(c *gin.Context) {
+	var (
+		request  MagicLinkRequest
+		response MagicLinkResponse
+	)
+
+	err := c.ShouldBind(&request)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "SMH00", err, global.FRIENDLY_MESSAGE)
+
+		/*start validation*/
+		valError, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, elem := range valError {
+				fieldName := elem.Field()
+				field, _ := reflect.TypeOf(&request).Elem().FieldByName(fieldName)
+				jsonField, _ := field.Tag.Lookup("json")
+
+				switch elem.Tag() {
+				case "required":
+					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
+					response.Message = append(response.Message, msg)
+					break
+				case "email":
+					msg := global.FRIENDLY_INVALID_EMAIL_FORMAT
+					response.Message = append(response.Message, msg)
+					break
+				}
+			}
+
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		/*end validation*/
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	p := bluemonday.UGCPolicy()
+	request.Email = p.Sanitize(request.Email)
+
+	//unknown emails get the same response, so the endpoint cannot be used to find accounts
+	err = ah.useCase.SendMagicLink(c.Request.Context(), request.Email)
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok || cerr.Type != cerror.TYPE_NOT_FOUND {
+			if !ok {
+				cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "SMH01", err, global.FRIENDLY_MESSAGE)
+			}
+			response.Message = append(response.Message, cerr.FriendlyMessageWithTag())
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+func (ah AuthHandler) LoginMagicLink(c *gin.Context) {
+	var (
+		request  VerifyMagicLinkRequest
+		response LoginResponse
+	)
+
+	err := c.ShouldBind(&request)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "LMH00", err, global.FRIENDLY_MESSAGE)
+
+		/*start validation*/
+		valError, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, elem := range valError {
+				fieldName := elem.Field()
+				field, _ := reflect.TypeOf(&request).Elem().FieldByName(fieldName)
+				jsonField, _ := field.Tag.Lookup("json")
+
+				switch elem.Tag() {
+				case "required":
+					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
+					response.Message = append(response.Message, msg)
+					break
+				}
+			}
+
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		/*end validation*/
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	token, challenge, err := ah.useCase.LoginMagicLink(c.Request.Context(), request.Token, ah.clientInfo(c))
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "LMH01", err, global.FRIENDLY_MESSAGE)
+		}
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+
+		httpStatus := http.StatusInternalServerError
+		if cerr.Type == cerror.TYPE_UNAUTHORIZED {
+			httpStatus = http.StatusUnauthorized
+		}
+
+		c.JSON(httpStatus, response)
+		return
+	}
+
+	if challenge != nil {
+		response.TwoFactorRequired = true
+		response.ChallengeToken = challenge.ChallengeToken
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response.AccessToken = token.AccessToken
+
+	cookie := ah.cookieHelper.SetHttpOnlyCookie("refresh_token", token.RefreshToken, token.RefreshTokenExpTime)
+	http.SetCookie(c.Writer, cookie)
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+func (ah AuthHandler) OIDCLogin(c *gin.Context) {
+	var response LoginResponse
+
+	authURL, state, err := ah.useCase.OIDCAuthURL(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "OLH00", err, global.FRIENDLY_MESSAGE)
+		}
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+
+		httpStatus := http.StatusInternalServerError
+		if cerr.Type == cerror.TYPE_NOT_FOUND {
+			httpStatus = http.StatusNotFound
+		}
+
+		c.JSON(httpStatus, response)
+		return
+	}
+
+	cookie := ah.cookieHelper.SetLaxHttpOnlyCookie(OIDC_STATE_COOKIE, hashOIDCState(state), OIDC_STATE_COOKIE_PATH, OIDC_STATE_COOKIE_TTL)
+	http.SetCookie(c.Writer, cookie)
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback is reached by the browser redirect from the provider, so every outcome is a
+// redirect to the frontend. On success only the refresh token cookie is set and the frontend
+// gets its access token from /api/auth/refresh_token. The state has to match the cookie set by
+// OIDCLogin, otherwise a callback started in another browser could log the user in as someone else.
+func (ah AuthHandler) OIDCCallback(c *gin.Context) {
+	frontendURL := ah.feHost + "/oauth_callback"
+
+	stateCookie, _ := c.Cookie(OIDC_STATE_COOKIE)
+	http.SetCookie(c.Writer, ah.cookieHelper.RemoveLaxHttpOnlyCookie(OIDC_STATE_COOKIE, OIDC_STATE_COOKIE_PATH))
+
+	query := c.Request.URL.Query()
+	if query.Get("error") != "" {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "OCH00", errors.New("provider returned error "+query.Get("error")), global.FRIENDLY_OIDC_FAILED)
+		c.Redirect(http.StatusFound, frontendURL+"?error="+url.QueryEscape(cerr.FriendlyMessageWithTag()))
+		return
+	}
+
+	if stateCookie == "" || subtle.ConstantTimeCompare([]byte(stateCookie), []byte(hashOIDCState(query.Get("state")))) != 1 {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "OCH02", errors.New("state does not match the state cookie"), global.FRIENDLY_OIDC_FAILED)
+		c.Redirect(http.StatusFound, frontendURL+"?error="+url.QueryEscape(cerr.FriendlyMessageWithTag()))
+		return
+	}
+
+	token, challenge, err := ah.useCase.LoginOIDC(c.Request.Context(), c.Param("provider"), query.Get("state"), query.Get("code"), ah.clientInfo(c))
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "OCH01", err, global.FRIENDLY_OIDC_FAILED)
+		}
+		c.Redirect(http.StatusFound, frontendURL+"?error="+url.QueryEscape(cerr.FriendlyMessageWithTag()))
+		return
+	}
+
+	if challenge != nil {
+		c.Redirect(http.StatusFound, frontendURL+"?challenge_token="+url.QueryEscape(challenge.ChallengeToken))
+		return
+	}
+
+	cookie := ah.cookieHelper.SetHttpOnlyCookie("refresh_token", token.RefreshToken, token.RefreshTokenExpTime)
+	http.SetCookie(c.Writer, cookie)
+
+	c.Redirect(http.StatusFound, frontendURL)
+}
+
+// hashOIDCState keeps the state itself out of the cookie
+func hashOIDCState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
 func (ah AuthHandler) SignUp(c *gin.Context) {
 	var (
 		request  SignUpRequest
@@ -174,7 +537,7 @@ func (ah AuthHandler) SignUp(c *gin.Context) {
 
 	err := c.ShouldBindWith(&request, binding.Form)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("ASU00", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "ASU00", err, global.FRIENDLY_MESSAGE)
 
 		/*start validation*/
 		valError := err.(validator.ValidationErrors)
@@ -224,12 +587,25 @@ func (ah AuthHandler) SignUp(c *gin.Context) {
 
 	var profile domain.Profile
 	profile.FullName = request.Fullname
+	profile.Locale = ah.preferredLocale(c)
 
 	//create account
-	createdAccount, createdProfile, err := ah.useCase.SignUp(account, profile)
+	createdAccount, createdProfile, err := ah.useCase.SignUp(c.Request.Context(), account, profile)
 	if err != nil {
-		err.(cerror.Error).PrintErrorWithTag()
-		response.Message = []string{err.(cerror.Error).FriendlyMessageWithTag()}
+		cerr := err.(cerror.Error)
+		if cerr.Type == cerror.TYPE_BAD_REQUEST && len(cerr.Details) > 0 {
+			response.Message = cerr.Details
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		if cerr.Type == cerror.TYPE_CONFLICT {
+			response.Message = []string{cerr.FriendlyMessageWithTag()}
+			c.JSON(http.StatusConflict, response)
+			return
+		}
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
 		c.JSON(http.StatusInternalServerError, response)
 		return
 	}
@@ -248,10 +624,10 @@ func (ah AuthHandler) RefreshToken(c *gin.Context) {
 		var status int
 		if err == http.ErrNoCookie {
 			status = http.StatusUnauthorized
-			cerr = cerror.NewAndPrintWithTag("RTH00", err, err.Error())
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "RTH00", err, err.Error())
 		} else {
 			status = http.StatusBadRequest
-			cerr = cerror.NewAndPrintWithTag("RTH01", err, global.FRIENDLY_MESSAGE)
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "RTH01", err, global.FRIENDLY_MESSAGE)
 		}
 
 		response.Message = cerr.FriendlyMessageWithTag()
@@ -261,7 +637,7 @@ func (ah AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	refreshToken := rtCookie.Value
-	token, err := ah.useCase.RefreshToken(refreshToken)
+	token, err := ah.useCase.RefreshToken(c.Request.Context(), refreshToken)
 	if err != nil {
 		//handle token expired
 		cerr, ok := err.(cerror.Error)
@@ -277,7 +653,7 @@ func (ah AuthHandler) RefreshToken(c *gin.Context) {
 			return
 		}
 
-		cerr = cerror.NewAndPrintWithTag("RTH02", err, global.FRIENDLY_MESSAGE)
+		cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "RTH02", err, global.FRIENDLY_MESSAGE)
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusInternalServerError, response)
 		return
@@ -287,8 +663,7 @@ func (ah AuthHandler) RefreshToken(c *gin.Context) {
 	response.AccessToken = token.AccessToken
 
 	//set new refresh token to cookie
-	cookieHelper := helper.CookieHelper{}
-	cookie := cookieHelper.SetHttpOnlyCookie("refresh_token", token.RefreshToken, token.RefreshTokenExpTime)
+	cookie := ah.cookieHelper.SetHttpOnlyCookie("refresh_token", token.RefreshToken, token.RefreshTokenExpTime)
 
 	http.SetCookie(c.Writer, cookie)
 
@@ -305,17 +680,20 @@ func (ah AuthHandler) VerifyEmail(c *gin.Context) {
 	query := c.Request.URL.Query()
 	if len(query) > 0 && query["token"] != nil && len(query["token"]) > 0 {
 		emailToken = query["token"][0]
-		err := ah.useCase.VerifyEmail(emailToken)
+		err := ah.useCase.VerifyEmail(c.Request.Context(), emailToken)
 		if err != nil {
-			_, ok := err.(cerror.Error)
-			if ok {
-				cerr := err.(cerror.Error)
-				response.Message = cerr.FriendlyMessageWithTag()
+			cerr, ok := err.(cerror.Error)
+			if !ok {
+				cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "VEH00", err, global.FRIENDLY_MESSAGE)
 			}
-
-			cerr := cerror.NewAndPrintWithTag("VEH00", err, global.FRIENDLY_MESSAGE)
 			response.Message = cerr.FriendlyMessageWithTag()
-			c.JSON(http.StatusInternalServerError, response)
+
+			switch cerr.Type {
+			case cerror.TYPE_NOT_FOUND, cerror.TYPE_EXPIRED:
+				c.JSON(http.StatusBadRequest, response)
+			default:
+				c.JSON(http.StatusInternalServerError, response)
+			}
 			return
 		}
 
@@ -327,6 +705,75 @@ func (ah AuthHandler) VerifyEmail(c *gin.Context) {
 	c.JSON(http.StatusBadRequest, response)
 }
 
+func (ah AuthHandler) ResendVerification(c *gin.Context) {
+	var (
+		request  ResendVerificationRequest
+		response ResendVerificationResponse
+	)
+
+	err := c.ShouldBind(&request)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "RVH00", err, global.FRIENDLY_MESSAGE)
+
+		/*start validation*/
+		valError, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, elem := range valError {
+				fieldName := elem.Field()
+				field, _ := reflect.TypeOf(&request).Elem().FieldByName(fieldName)
+				jsonField, _ := field.Tag.Lookup("json")
+
+				switch elem.Tag() {
+				case "required":
+					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
+					response.Message = append(response.Message, msg)
+					break
+				case "email":
+					msg := global.FRIENDLY_INVALID_EMAIL_FORMAT
+					response.Message = append(response.Message, msg)
+					break
+				}
+			}
+
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		/*end validation*/
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	p := bluemonday.UGCPolicy()
+	request.Email = p.Sanitize(request.Email)
+
+	//unknown and already verified emails get the same response as a successful resend
+	err = ah.useCase.ResendVerification(c.Request.Context(), request.Email)
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "RVH01", err, global.FRIENDLY_MESSAGE)
+		}
+
+		switch cerr.Type {
+		case cerror.TYPE_NOT_FOUND:
+			break
+		case cerror.TYPE_TOO_MANY_REQUESTS:
+			response.Message = []string{cerr.FriendlyMessageWithTag()}
+			c.JSON(http.StatusTooManyRequests, response)
+			return
+		default:
+			response.Message = []string{cerr.FriendlyMessageWithTag()}
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
 func (ah AuthHandler) ResetPassword(c *gin.Context) {
 	var (
 		request  ResetPasswordRequest
@@ -335,7 +782,7 @@ func (ah AuthHandler) ResetPassword(c *gin.Context) {
 
 	err := c.ShouldBind(&request)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("RPH00", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "RPH00", err, global.FRIENDLY_MESSAGE)
 
 		/*start validation*/
 		valError := err.(validator.ValidationErrors)
@@ -370,7 +817,7 @@ func (ah AuthHandler) ResetPassword(c *gin.Context) {
 	p := bluemonday.UGCPolicy()
 	request.Email = p.Sanitize(request.Email)
 
-	err = ah.useCase.ResetPassword(request.Email)
+	err = ah.useCase.ResetPassword(c.Request.Context(), request.Email, ah.clientInfo(c))
 	if err != nil {
 		cerr, ok := err.(cerror.Error)
 		if ok {
@@ -395,7 +842,7 @@ func (ah AuthHandler) ChangePassword(c *gin.Context) {
 
 	err := c.ShouldBind(&request)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("CPH00", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "CPH00", err, global.FRIENDLY_MESSAGE)
 
 		/*start form validation*/
 		valError := err.(validator.ValidationErrors)
@@ -431,17 +878,23 @@ func (ah AuthHandler) ChangePassword(c *gin.Context) {
 	query := c.Request.URL.Query()
 	if len(query) > 0 && query["token"] != nil && len(query["token"]) > 0 { //token validation
 		resetPasswordToken = query["token"][0]
-		err := ah.useCase.ChangePassword(resetPasswordToken, request.Password)
+		err := ah.useCase.ChangePassword(c.Request.Context(), resetPasswordToken, request.Password, ah.clientInfo(c))
 		if err != nil {
 			cerr, ok := err.(cerror.Error)
-			if ok {
-				response.Message = []string{cerr.FriendlyMessageWithTag()}
-			} else {
-				cerr := cerror.NewAndPrintWithTag("CPH01", err, global.FRIENDLY_MESSAGE)
-				response.Message = []string{cerr.FriendlyMessageWithTag()}
+			if !ok {
+				cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "CPH01", err, global.FRIENDLY_MESSAGE)
+			}
+			response.Message = []string{cerr.FriendlyMessageWithTag()}
+			if len(cerr.Details) > 0 {
+				response.Message = cerr.Details
 			}
 
-			c.JSON(http.StatusInternalServerError, response)
+			switch cerr.Type {
+			case cerror.TYPE_NOT_FOUND, cerror.TYPE_EXPIRED, cerror.TYPE_BAD_REQUEST:
+				c.JSON(http.StatusBadRequest, response)
+			default:
+				c.JSON(http.StatusInternalServerError, response)
+			}
 			return
 		}
 
@@ -457,19 +910,19 @@ func (ah AuthHandler) ChangePassword(c *gin.Context) {
 
 func (ah AuthHandler) SignOut(c *gin.Context) {
 	var (
-		response                  SignOutResponse
-		jwtHelper                 helper.JWTHelper
-		accessToken, refreshToken *jwt.Token
-		err                       error
+		response     SignOutResponse
+		accessToken  *helper.AccessTokenClaims
+		refreshToken *helper.RefreshTokenClaims
+		err          error
 	)
 	//remove access token
 	authArr := c.Request.Header["Authorization"]
 	if len(authArr) > 0 {
 		accessTokenString := authArr[0] //get access token from header
 
-		accessToken, err = jwtHelper.ParseToken(accessTokenString) //parse token component into struct
+		accessToken, err = ah.jwtHelper.ParseAccessToken(accessTokenString) //parse token component into struct
 		if err != nil {
-			cerr := cerror.NewAndPrintWithTag("SOA00", err, global.FRIENDLY_INVALID_TOKEN)
+			cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "SOA00", err, global.FRIENDLY_INVALID_TOKEN)
 			response.ErrprType = "token_invalid"
 			response.Message = cerr.FriendlyMessageWithTag()
 			c.JSON(http.StatusBadRequest, response)
@@ -480,23 +933,23 @@ func (ah AuthHandler) SignOut(c *gin.Context) {
 	//get refresh token
 	rtCookie, err := c.Request.Cookie("refresh_token")
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("SOA01", err, global.FRIENDLY_INVALID_TOKEN)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "SOA01", err, global.FRIENDLY_INVALID_TOKEN)
 		response.ErrprType = "token_invalid"
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusBadRequest, response)
 		return
 	}
 
-	refreshToken, err = jwtHelper.ParseToken(rtCookie.Value) //parse token component into struct
+	refreshToken, err = ah.jwtHelper.ParseRefreshToken(rtCookie.Value) //parse token component into struct
 	if err != nil {
 		response.ErrprType = "token_invalid"
-		cerr := cerror.NewAndPrintWithTag("SOA02", err, global.FRIENDLY_INVALID_TOKEN)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "SOA02", err, global.FRIENDLY_INVALID_TOKEN)
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusBadRequest, response)
 		return
 	}
 
-	err = ah.useCase.SignOut(accessToken, refreshToken)
+	err = ah.useCase.SignOut(c.Request.Context(), accessToken, refreshToken)
 	if err != nil {
 		cerr := err.(cerror.Error)
 		response.Message = cerr.FriendlyMessageWithTag()
@@ -504,9 +957,238 @@ func (ah AuthHandler) SignOut(c *gin.Context) {
 		return
 	}
 
-	cookieHelper := helper.CookieHelper{}
-	cookie := cookieHelper.RemoveHttpOnlyCookie("refresh_token")
+	cookie := ah.cookieHelper.RemoveHttpOnlyCookie("refresh_token")
+	http.SetCookie(c.Writer, cookie)
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+func (ah AuthHandler) EnrollTOTP(c *gin.Context) {
+	var (
+		response  EnrollTOTPResponse
+		accountID string = c.GetString("account_id")
+	)
+
+	enrollment, err := ah.useCase.EnrollTOTP(c.Request.Context(), accountID)
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "ETH00", err, global.FRIENDLY_MESSAGE)
+		}
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+
+		httpStatus := http.StatusInternalServerError
+		if cerr.Type == cerror.TYPE_BAD_REQUEST {
+			httpStatus = http.StatusBadRequest
+		}
+
+		c.JSON(httpStatus, response)
+		return
+	}
+
+	response.Secret = enrollment.Secret
+	response.URI = enrollment.URI
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+func (ah AuthHandler) ConfirmTOTP(c *gin.Context) {
+	var (
+		request   TOTPCodeRequest
+		response  ConfirmTOTPResponse
+		accountID string = c.GetString("account_id")
+	)
+
+	err := c.ShouldBind(&request)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "CTH00", err, global.FRIENDLY_MESSAGE)
+
+		/*start validation*/
+		valError, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, elem := range valError {
+				fieldName := elem.Field()
+				field, _ := reflect.TypeOf(&request).Elem().FieldByName(fieldName)
+				jsonField, _ := field.Tag.Lookup("json")
+
+				switch elem.Tag() {
+				case "required":
+					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
+					response.Message = append(response.Message, msg)
+					break
+				}
+			}
+
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		/*end validation*/
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	recoveryCodes, err := ah.useCase.ConfirmTOTP(c.Request.Context(), accountID, request.Code, ah.clientInfo(c))
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "CTH01", err, global.FRIENDLY_MESSAGE)
+		}
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+
+		httpStatus := http.StatusInternalServerError
+		if cerr.Type == cerror.TYPE_BAD_REQUEST || cerr.Type == cerror.TYPE_UNAUTHORIZED {
+			httpStatus = http.StatusBadRequest
+		}
+
+		c.JSON(httpStatus, response)
+		return
+	}
+
+	response.RecoveryCodes = recoveryCodes
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+func (ah AuthHandler) DisableTOTP(c *gin.Context) {
+	var (
+		request   TOTPCodeRequest
+		response  DisableTOTPResponse
+		accountID string = c.GetString("account_id")
+	)
+
+	err := c.ShouldBind(&request)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "DTH00", err, global.FRIENDLY_MESSAGE)
+
+		/*start validation*/
+		valError, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, elem := range valError {
+				fieldName := elem.Field()
+				field, _ := reflect.TypeOf(&request).Elem().FieldByName(fieldName)
+				jsonField, _ := field.Tag.Lookup("json")
+
+				switch elem.Tag() {
+				case "required":
+					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
+					response.Message = append(response.Message, msg)
+					break
+				}
+			}
+
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		/*end validation*/
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = ah.useCase.DisableTOTP(c.Request.Context(), accountID, request.Code, ah.clientInfo(c))
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "DTH01", err, global.FRIENDLY_MESSAGE)
+		}
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+
+		httpStatus := http.StatusInternalServerError
+		if cerr.Type == cerror.TYPE_BAD_REQUEST || cerr.Type == cerror.TYPE_UNAUTHORIZED {
+			httpStatus = http.StatusBadRequest
+		}
+
+		c.JSON(httpStatus, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+func (ah AuthHandler) DeleteAccount(c *gin.Context) {
+	var (
+		request   DeleteAccountRequest
+		response  DeleteAccountResponse
+		accountID string = c.GetString("account_id")
+	)
+
+	err := c.ShouldBind(&request)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "DAH00", err, global.FRIENDLY_MESSAGE)
+
+		/*start validation*/
+		valError, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, elem := range valError {
+				fieldName := elem.Field()
+				field, _ := reflect.TypeOf(&request).Elem().FieldByName(fieldName)
+				jsonField, _ := field.Tag.Lookup("json")
+
+				switch elem.Tag() {
+				case "required":
+					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
+					response.Message = append(response.Message, msg)
+					break
+				}
+			}
+
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		/*end validation*/
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = ah.useCase.DeleteAccount(c.Request.Context(), accountID, request.Password, request.Code)
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "DAH01", err, global.FRIENDLY_MESSAGE)
+		}
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+
+		httpStatus := http.StatusInternalServerError
+		if cerr.Type == cerror.TYPE_BAD_REQUEST || cerr.Type == cerror.TYPE_UNAUTHORIZED {
+			httpStatus = http.StatusBadRequest
+		}
+
+		c.JSON(httpStatus, response)
+		return
+	}
+
+	cookie := ah.cookieHelper.RemoveHttpOnlyCookie("refresh_token")
 	http.SetCookie(c.Writer, cookie)
 	c.JSON(http.StatusOK, response)
 	return
 }
+
+func (ah AuthHandler) JWKS(c *gin.Context) {
+	//verifiers may cache the keys, scheduled keys are published before they sign anything
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, ah.jwtHelper.JWKS())
+}
+
+func (ah AuthHandler) clientInfo(c *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// preferredLocale returns the first language of the Accept-Language header, for example
+// "id-ID" for "id-ID,id;q=0.9,en;q=0.8". An empty string means the default locale.
+func (ah AuthHandler) preferredLocale(c *gin.Context) string {
+	header := c.GetHeader("Accept-Language")
+	tag := strings.TrimSpace(strings.SplitN(strings.SplitN(header, ",", 2)[0], ";", 2)[0])
+	if tag == "*" || len(tag) > MAX_LOCALE_LENGTH {
+		return ""
+	}
+	return tag
+}
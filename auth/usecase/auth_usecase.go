@@ -1,411 +1,1302 @@
 package usecase
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
 	"github.com/pajri/personal-backend/adapter/cerror"
-	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/adapter/logger"
 	"github.com/pajri/personal-backend/domain"
 	"github.com/pajri/personal-backend/global"
 	"github.com/pajri/personal-backend/helper"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/pajri/personal-backend/metrics"
 )
 
-const SALT_BYTES = 32
+const (
+	RECOVERY_CODE_COUNT          = 10
+	RECOVERY_CODE_BYTES          = 10
+	LOGIN_CHALLENGE_MAX_ATTEMPTS = 5
+	LOGIN_CHALLENGE_TTL          = 5 * time.Minute
+	MAGIC_LINK_TOKEN_BYTES       = 32
+	MAGIC_LINK_TTL               = 15 * time.Minute
+	OIDC_RANDOM_BYTES            = 32
+	OIDC_STATE_TTL               = 10 * time.Minute
+	ACCOUNT_TOKEN_BYTES          = 32
+	EMAIL_VERIFICATION_TTL       = 15 * time.Minute
+	PASSWORD_RESET_TTL           = 24 * time.Hour
+
+	DEFAULT_RESEND_VERIFICATION_COOLDOWN = 60
+
+	LOGIN_CHALLENGE_KEY         = "login_challenge:"
+	LOGIN_CHALLENGE_ATTEMPT_KEY = "login_challenge_attempt:"
+	TOTP_USED_KEY               = "totp_used:"
+	MAGIC_LINK_KEY              = "magic_link:"
+	OIDC_STATE_KEY              = "oidc_state:"
+	RESEND_VERIFICATION_KEY     = "resend_verification:"
+	ACCOUNT_SESSION_KEY         = "account_session:"
+)
+
+// AuthConfig holds the settings of AuthUsecase. FEHost is the frontend the links in emails
+// point to.
+type AuthConfig struct {
+	FEHost                string
+	TOTPIssuer            string
+	RecoveryCodeSecret    string
+	ResendCooldownSeconds int
+}
 
 type AuthUsecase struct {
-	accountRepo domain.IAccountRepository
-	profileRepo domain.IProfileRepository
-	mailHelper  helper.IEMail
+	accountRepo          domain.IAccountRepository
+	profileRepo          domain.IProfileRepository
+	recoveryCodeRepo     domain.IRecoveryCodeRepository
+	accountTokenRepo     domain.IAccountTokenRepository
+	accountDeviceRepo    domain.IAccountDeviceRepository
+	externalIdentityRepo domain.IExternalIdentityRepository
+	postRepo             domain.IPostRepository
+	imageRepo            domain.IImageRepository
+	transactor           domain.ITransactor
+	mailHelper           helper.IEMail
+	oidcHelper           helper.IOIDC
+	passwordHasher       helper.IPasswordHasher
+	passwordPolicy       helper.IPasswordPolicy
+	redisHelper          helper.IRedis
+	jwtHelper            helper.JWTHelper
+	config               AuthConfig
+	metrics              *metrics.Metrics
+}
+
+// oidcState is kept in redis between the redirect to the provider and the callback.
+type oidcState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
 }
 
 func NewAuthUsecase(accountRepository domain.IAccountRepository,
 	profileRepository domain.IProfileRepository,
-	_mailHelper helper.IEMail) domain.IAuthUsecase {
+	recoveryCodeRepository domain.IRecoveryCodeRepository,
+	accountTokenRepository domain.IAccountTokenRepository,
+	accountDeviceRepository domain.IAccountDeviceRepository,
+	externalIdentityRepository domain.IExternalIdentityRepository,
+	postRepository domain.IPostRepository,
+	imageRepository domain.IImageRepository,
+	_transactor domain.ITransactor,
+	_mailHelper helper.IEMail,
+	_oidcHelper helper.IOIDC,
+	_passwordHasher helper.IPasswordHasher,
+	_passwordPolicy helper.IPasswordPolicy,
+	_redisHelper helper.IRedis,
+	_jwtHelper helper.JWTHelper,
+	authConfig AuthConfig,
+	_metrics *metrics.Metrics) domain.IAuthUsecase {
 	return &AuthUsecase{
-		accountRepo: accountRepository,
-		profileRepo: profileRepository,
-		mailHelper:  _mailHelper,
+		accountRepo:          accountRepository,
+		profileRepo:          profileRepository,
+		recoveryCodeRepo:     recoveryCodeRepository,
+		accountTokenRepo:     accountTokenRepository,
+		accountDeviceRepo:    accountDeviceRepository,
+		externalIdentityRepo: externalIdentityRepository,
+		postRepo:             postRepository,
+		imageRepo:            imageRepository,
+		transactor:           _transactor,
+		mailHelper:           _mailHelper,
+		oidcHelper:           _oidcHelper,
+		passwordHasher:       _passwordHasher,
+		passwordPolicy:       _passwordPolicy,
+		redisHelper:          _redisHelper,
+		jwtHelper:            _jwtHelper,
+		config:               authConfig,
+		metrics:              _metrics,
 	}
 }
 
-func (uc AuthUsecase) Login(account domain.Account) (*helper.JWTWrapper, error) {
+func (uc AuthUsecase) Login(ctx context.Context, account domain.Account, client domain.ClientInfo) (*helper.JWTWrapper, *domain.LoginChallenge, error) {
+	token, challenge, err := uc.login(ctx, account, client)
+	uc.metrics.ObserveLogin(metrics.LOGIN_METHOD_PASSWORD, challenge != nil, err)
+	return token, challenge, err
+}
+
+func (uc AuthUsecase) login(ctx context.Context, account domain.Account, client domain.ClientInfo) (*helper.JWTWrapper, *domain.LoginChallenge, error) {
 	filter := domain.AccountFilter{Email: account.Email}
-	regAccount, err := uc.accountRepo.GetAccount(filter)
+	regAccount, err := uc.accountRepo.GetAccount(ctx, filter)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	err, ok := uc.comparePassword([]byte(account.Password), regAccount.Salt, []byte(regAccount.Password))
+	ok, err := uc.passwordHasher.Verify(account.Password, regAccount.Password, regAccount.Salt)
 	if !ok || err != nil {
-		cerr := cerror.NewAndPrintWithTag("LGU03",
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "LGU03",
 			errors.New("incorrect password for email :"+account.Email),
 			global.FRIENDLY_INVALID_USNME_PASSWORD)
 		cerr.Type = cerror.TYPE_UNAUTHORIZED
 
-		return nil, cerr
+		return nil, nil, cerr
+	}
+
+	//the password is only known here, so this is where old hashes are upgraded
+	if uc.passwordHasher.NeedsRehash(regAccount.Password) {
+		err = uc.updatePassword(ctx, *regAccount, account.Password)
+		if err != nil {
+			logger.WithTag(ctx, "LGU05").WithError(err).Warn("unable to rehash password of ", regAccount.Email)
+		}
 	}
 
 	if regAccount != nil {
 		if !regAccount.IsVerified {
 			err := fmt.Errorf("email %s has not been verified", regAccount.Email)
-			cerr := cerror.NewAndPrintWithTag("LGU04", err, global.FRIENDLY_EMAIL_NOT_VERIFIED)
-			return nil, cerr
+			cerr := cerror.NewAndPrintWithTagContext(ctx, "LGU04", err, global.FRIENDLY_EMAIL_NOT_VERIFIED)
+			return nil, nil, cerr
 		}
 
-		filterProfile := domain.ProfileFilter{AccountID: regAccount.AccountID}
-		regProfile, err := uc.profileRepo.GetProfile(filterProfile)
+		return uc.completeLogin(ctx, *regAccount, client)
+	}
+
+	userNilErr := cerror.NewAndPrintWithTagContext(ctx, "LGU02", errors.New("user nil"), global.FRIENDLY_INVALID_USNME_PASSWORD)
+	return nil, nil, userNilErr
+}
+
+func (uc AuthUsecase) SendMagicLink(ctx context.Context, email string) error {
+	filter := domain.AccountFilter{Email: email}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil || !account.IsVerified {
+		//the handler answers the same way for unknown and unverified emails
+		err = fmt.Errorf("email %s is not found or not verified", email)
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "SML00", err, "")
+		cerr.Type = cerror.TYPE_NOT_FOUND
+		return cerr
+	}
+
+	token, err := uc.generateRandomToken(MAGIC_LINK_TOKEN_BYTES)
+	if err != nil {
+		return err
+	}
+
+	exp := time.Now().Add(MAGIC_LINK_TTL).Unix()
+	err = uc.redisHelper.Set(ctx, MAGIC_LINK_KEY+token, account.AccountID, exp)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"Link":             uc.generateMagicLinkUrl(token),
+		"ExpiresInMinutes": int(MAGIC_LINK_TTL.Minutes()),
+	}
+	to := []string{account.Email}
+	err = uc.mailHelper.SendMail(ctx, to, uc.accountLocale(ctx, *account), global.EMAIL_TEMPLATE_MAGIC_LINK, data)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (uc AuthUsecase) LoginMagicLink(ctx context.Context, token string, client domain.ClientInfo) (*helper.JWTWrapper, *domain.LoginChallenge, error) {
+	jwtToken, challenge, err := uc.loginMagicLink(ctx, token, client)
+	uc.metrics.ObserveLogin(metrics.LOGIN_METHOD_MAGIC_LINK, challenge != nil, err)
+	return jwtToken, challenge, err
+}
+
+func (uc AuthUsecase) loginMagicLink(ctx context.Context, token string, client domain.ClientInfo) (*helper.JWTWrapper, *domain.LoginChallenge, error) {
+	//taking the token out of redis is what makes the link single use
+	accountID, _ := uc.redisHelper.GetAndDelete(ctx, MAGIC_LINK_KEY+token)
+	if accountID == "" {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "LML00", errors.New("magic link token not found"), global.FRIENDLY_INVALID_TOKEN)
+		cerr.Type = cerror.TYPE_UNAUTHORIZED
+		return nil, nil, cerr
+	}
+
+	filter := domain.AccountFilter{AccountID: accountID}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return uc.completeLogin(ctx, *account, client)
+}
+
+// OIDCAuthURL builds the provider authorization URL. The state is returned separately so the
+// delivery can bind it to the browser that started the login.
+func (uc AuthUsecase) OIDCAuthURL(ctx context.Context, provider string) (string, string, error) {
+	state, err := uc.generateRandomToken(OIDC_RANDOM_BYTES)
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := uc.generateRandomToken(OIDC_RANDOM_BYTES)
+	if err != nil {
+		return "", "", err
+	}
+
+	codeVerifier, err := uc.generateRandomToken(OIDC_RANDOM_BYTES)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := uc.oidcHelper.AuthCodeURL(ctx, provider, state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := json.Marshal(oidcState{Provider: provider, Nonce: nonce, CodeVerifier: codeVerifier})
+	if err != nil {
+		return "", "", cerror.NewAndPrintWithTagContext(ctx, "OAU00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = uc.redisHelper.Set(ctx, OIDC_STATE_KEY+state, string(payload), time.Now().Add(OIDC_STATE_TTL).Unix())
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+func (uc AuthUsecase) LoginOIDC(ctx context.Context, provider, state, code string, client domain.ClientInfo) (*helper.JWTWrapper, *domain.LoginChallenge, error) {
+	token, challenge, err := uc.loginOIDC(ctx, provider, state, code, client)
+	uc.metrics.ObserveLogin(metrics.LOGIN_METHOD_OIDC, challenge != nil, err)
+	return token, challenge, err
+}
+
+func (uc AuthUsecase) loginOIDC(ctx context.Context, provider, state, code string, client domain.ClientInfo) (*helper.JWTWrapper, *domain.LoginChallenge, error) {
+	//the state is single use, a replayed callback will not find it
+	payload, _ := uc.redisHelper.GetAndDelete(ctx, OIDC_STATE_KEY+state)
+	if payload == "" {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "LOU00", errors.New("oidc state not found"), global.FRIENDLY_OIDC_FAILED)
+		cerr.Type = cerror.TYPE_UNAUTHORIZED
+		return nil, nil, cerr
+	}
+
+	var storedState oidcState
+	err := json.Unmarshal([]byte(payload), &storedState)
+	if err != nil {
+		return nil, nil, cerror.NewAndPrintWithTagContext(ctx, "LOU01", err, global.FRIENDLY_OIDC_FAILED)
+	}
+
+	if storedState.Provider != provider {
+		err = fmt.Errorf("state was issued for provider %s, callback is for %s", storedState.Provider, provider)
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "LOU02", err, global.FRIENDLY_OIDC_FAILED)
+		cerr.Type = cerror.TYPE_UNAUTHORIZED
+		return nil, nil, cerr
+	}
+
+	identity, err := uc.oidcHelper.Exchange(ctx, provider, code, storedState.CodeVerifier, storedState.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account, err := uc.findOrCreateOIDCAccount(ctx, *identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return uc.completeLogin(ctx, *account, client)
+}
+
+// findOrCreateOIDCAccount returns the account linked to the external identity. An identity
+// seen for the first time is linked to the account with the same email, or to a new verified
+// account when there is none. An unverified account is claimed first, see
+// claimUnverifiedAccount.
+func (uc AuthUsecase) findOrCreateOIDCAccount(ctx context.Context, identity helper.OIDCIdentity) (*domain.Account, error) {
+	identityFilter := domain.ExternalIdentityFilter{Provider: identity.Provider, Subject: identity.Subject}
+	linkedIdentity, err := uc.externalIdentityRepo.GetExternalIdentity(ctx, identityFilter)
+	if err == nil {
+		filter := domain.AccountFilter{AccountID: linkedIdentity.AccountID}
+		return uc.accountRepo.GetAccount(ctx, filter)
+	}
+
+	if !uc.isNotFound(err) {
+		return nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		err = fmt.Errorf("%s identity %s has no verified email", identity.Provider, identity.Subject)
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "FOA00", err, global.FRIENDLY_OIDC_EMAIL_UNVERIFIED)
+		cerr.Type = cerror.TYPE_UNAUTHORIZED
+		return nil, cerr
+	}
+
+	filter := domain.AccountFilter{Email: identity.Email}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil && !uc.isNotFound(err) {
+		return nil, err
+	}
+
+	if account == nil {
+		account, err = uc.createOIDCAccount(ctx, identity)
 		if err != nil {
 			return nil, err
 		}
-
-		token, err := uc.createTokenPair(*regAccount, *regProfile)
+	} else if !account.IsVerified {
+		err = uc.claimUnverifiedAccount(ctx, account)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		return token, nil
+	newIdentity := domain.ExternalIdentity{
+		AccountID: account.AccountID,
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+	}
+	err = uc.externalIdentityRepo.InsertExternalIdentity(ctx, newIdentity)
+	if err != nil {
+		return nil, err
 	}
 
-	userNilErr := cerror.NewAndPrintWithTag("LGU02", errors.New("user nil"), global.FRIENDLY_INVALID_USNME_PASSWORD)
-	return nil, userNilErr
+	return account, nil
 }
 
-func (uc AuthUsecase) SignUp(account domain.Account, profile domain.Profile) (*domain.Account, *domain.Profile, error) {
-	//create salt
-	var err error
-	account.Salt, err = uc.generateSalt()
+// claimUnverifiedAccount hands an unverified account over to the owner of its email, whom the
+// provider has just proven. Anyone could have signed up with that email, so the password, second
+// factor and sessions that were set before are dropped instead of being kept on a now verified
+// account. The owner can set a password through a reset.
+func (uc AuthUsecase) claimUnverifiedAccount(ctx context.Context, account *domain.Account) error {
+	randomPassword, err := uc.generateRandomToken(OIDC_RANDOM_BYTES)
+	if err != nil {
+		return err
+	}
+
+	err = uc.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		err := uc.updatePassword(ctx, *account, randomPassword)
+		if err != nil {
+			return err
+		}
+
+		account.TOTPSecret = ""
+		account.IsTOTPEnabled = false
+		err = uc.accountRepo.UpdateTOTP(ctx, *account)
+		if err != nil {
+			return err
+		}
+
+		err = uc.recoveryCodeRepo.DeleteRecoveryCodes(ctx, account.AccountID)
+		if err != nil {
+			return err
+		}
+
+		err = uc.accountDeviceRepo.DeleteAccountDevices(ctx, account.AccountID)
+		if err != nil {
+			return err
+		}
+
+		err = uc.accountTokenRepo.DeleteAccountTokens(ctx, account.AccountID)
+		if err != nil {
+			return err
+		}
+
+		return uc.accountRepo.UpdateIsVerified(ctx, account.AccountID, true)
+	})
+	if err != nil {
+		return err
+	}
+	account.IsVerified = true
+
+	return uc.revokeSessions(ctx, account.AccountID)
+}
+
+func (uc AuthUsecase) createOIDCAccount(ctx context.Context, identity helper.OIDCIdentity) (*domain.Account, error) {
+	var (
+		account domain.Account
+		err     error
+	)
+
+	//the account can only sign in through the provider until the user resets the password
+	randomPassword, err := uc.generateRandomToken(OIDC_RANDOM_BYTES)
+	if err != nil {
+		return nil, err
+	}
+
+	account.Email = identity.Email
+	account.IsVerified = true
+	account.Password, err = uc.passwordHasher.Hash(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile domain.Profile
+	profile.FullName = identity.Name
+	if profile.FullName == "" {
+		profile.FullName = strings.Split(identity.Email, "@")[0]
+	}
+
+	var insertedAccount *domain.Account
+	err = uc.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		insertedAccount, err = uc.accountRepo.InsertAccount(ctx, account)
+		if err != nil {
+			return err
+		}
+
+		profile.AccountID = insertedAccount.AccountID
+		return uc.profileRepo.InsertProfile(ctx, profile)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return insertedAccount, nil
+}
+
+// completeLogin is the last step of every first factor login. It issues a token pair,
+// or a login challenge when the account has two-factor authentication enabled.
+func (uc AuthUsecase) completeLogin(ctx context.Context, account domain.Account, client domain.ClientInfo) (*helper.JWTWrapper, *domain.LoginChallenge, error) {
+	if account.IsTOTPEnabled {
+		challenge, err := uc.createLoginChallenge(ctx, account)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return nil, challenge, nil
+	}
+
+	filterProfile := domain.ProfileFilter{AccountID: account.AccountID}
+	profile, err := uc.profileRepo.GetProfile(ctx, filterProfile)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	account.Password, err = uc.hashPassword([]byte(account.Password), account.Salt)
+	token, err := uc.createTokenPair(ctx, account, *profile)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	emailTokenClaims := jwt.MapClaims{}
-	emailTokenClaims["account_id"] = uuid.New().String()
-	emailTokenClaims["email"] = account.Email
-	emailTokenClaims["exp"] = time.Now().Add(15 * time.Minute).Unix()
+	uc.recordLogin(ctx, account, client)
+	return token, nil, nil
+}
+
+func (uc AuthUsecase) LoginTOTP(ctx context.Context, challengeToken, code string, client domain.ClientInfo) (*helper.JWTWrapper, error) {
+	token, err := uc.loginTOTP(ctx, challengeToken, code, client)
+	uc.metrics.ObserveLogin(metrics.LOGIN_METHOD_TOTP, false, err)
+	return token, err
+}
+
+func (uc AuthUsecase) loginTOTP(ctx context.Context, challengeToken, code string, client domain.ClientInfo) (*helper.JWTWrapper, error) {
+	challengeKey := LOGIN_CHALLENGE_KEY + challengeToken
+	attemptKey := LOGIN_CHALLENGE_ATTEMPT_KEY + challengeToken
+
+	accountID, _ := uc.redisHelper.Get(ctx, challengeKey)
+	if accountID == "" {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "LTU00", errors.New("login challenge not found"), global.FRIENDLY_LOGIN_CHALLENGE_EXPIRED)
+		cerr.Type = cerror.TYPE_EXPIRED
+		return nil, cerr
+	}
+
+	//limit guesses per challenge, otherwise a 6 digit code could be brute forced
+	attempt, err := uc.redisHelper.Incr(ctx, attemptKey, time.Now().Add(LOGIN_CHALLENGE_TTL).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	if attempt > LOGIN_CHALLENGE_MAX_ATTEMPTS {
+		uc.redisHelper.Delete(ctx, challengeKey)
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "LTU01", fmt.Errorf("too many attempts for account %s", accountID), global.FRIENDLY_LOGIN_CHALLENGE_EXPIRED)
+		cerr.Type = cerror.TYPE_EXPIRED
+		return nil, cerr
+	}
+
+	filter := domain.AccountFilter{AccountID: accountID}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
 
-	jwtHelper := helper.JWTHelper{}
-	account.EmailToken, err = jwtHelper.CreateToken(emailTokenClaims)
+	err = uc.verifySecondFactor(ctx, *account, code)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.redisHelper.Delete(ctx, challengeKey)
+	uc.redisHelper.Delete(ctx, attemptKey)
+
+	filterProfile := domain.ProfileFilter{AccountID: account.AccountID}
+	profile, err := uc.profileRepo.GetProfile(ctx, filterProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := uc.createTokenPair(ctx, *account, *profile)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.recordLogin(ctx, *account, client)
+	return token, nil
+}
+
+func (uc AuthUsecase) SignUp(ctx context.Context, account domain.Account, profile domain.Profile) (*domain.Account, *domain.Profile, error) {
+	err := uc.checkPasswordPolicy(account.Password, account.Email, profile.FullName)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	insertedAccount, err := uc.accountRepo.InsertAccount(account)
+	account.Password, err = uc.passwordHasher.Hash(account.Password)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if insertedAccount != nil {
-		profile.AccountID = insertedAccount.AccountID
-		err = uc.profileRepo.InsertProfile(profile)
+	//the account, its profile and the verification token are stored together or not at all,
+	//so a failed sign up can simply be tried again with the same email
+	var (
+		insertedAccount *domain.Account
+		token           string
+	)
+	err = uc.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		insertedAccount, err = uc.accountRepo.InsertAccount(ctx, account)
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
 
-	} else {
-		log.Println("[SGU00] insertedAccount is nil")
-	}
+		profile.AccountID = insertedAccount.AccountID
+		err = uc.profileRepo.InsertProfile(ctx, profile)
+		if err != nil || insertedAccount.IsVerified {
+			return err
+		}
 
-	msg := fmt.Sprintf(global.VERIFY_EMAIL_TEMPLATE, uc.generateEmailConfirmationUrl(*insertedAccount))
-	to := []string{insertedAccount.Email}
-	subject := config.Config.EmailVerification.Subject
-	err = uc.mailHelper.SendMail(to, subject, msg)
+		token, err = uc.createAccountToken(ctx, *insertedAccount, domain.ACCOUNT_TOKEN_PURPOSE_EMAIL_VERIFICATION, EMAIL_VERIFICATION_TTL)
+		return err
+	})
 	if err != nil {
 		return nil, nil, err
 	}
 
+	//accounts created verified, e.g. by an operator, have nothing to verify
+	if insertedAccount.IsVerified {
+		return insertedAccount, &profile, nil
+	}
+
+	//the account exists at this point, a verification email that could not be queued can be resent
+	err = uc.sendVerificationEmail(ctx, *insertedAccount, token)
+	if err != nil {
+		logger.WithTag(ctx, "SGU00").WithError(err).Warn("unable to queue verification email to ", insertedAccount.Email)
+	}
+
 	return insertedAccount, &profile, nil
 }
 
-func (uc AuthUsecase) RefreshToken(refreshToken string) (*helper.JWTWrapper, error) {
-	jwtHelper := helper.JWTHelper{}
-	token, err := jwtHelper.ParseToken(refreshToken)
+func (uc AuthUsecase) ResendVerification(ctx context.Context, email string) error {
+	//the cooldown is set for every address, known or not, so it does not reveal which exist
+	cooldown := uc.config.ResendCooldownSeconds
+	if cooldown <= 0 {
+		cooldown = DEFAULT_RESEND_VERIFICATION_COOLDOWN
+	}
+
+	cooldownKey := RESEND_VERIFICATION_KEY + strings.ToLower(email)
+	cooldownExp := time.Now().Add(time.Duration(cooldown) * time.Second).Unix()
+	isSet, err := uc.redisHelper.SetNX(ctx, cooldownKey, "1", cooldownExp)
+	if err != nil {
+		return err
+	}
+
+	if !isSet {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "RVU00", fmt.Errorf("resend verification for %s is cooling down", email), global.FRIENDLY_RESEND_COOLDOWN)
+		cerr.Type = cerror.TYPE_TOO_MANY_REQUESTS
+		return cerr
+	}
+
+	filter := domain.AccountFilter{Email: email}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil || account.IsVerified {
+		err = fmt.Errorf("email %s is not found or already verified", email)
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "RVU01", err, "")
+		cerr.Type = cerror.TYPE_NOT_FOUND
+		return cerr
+	}
+
+	token, err := uc.createAccountToken(ctx, *account, domain.ACCOUNT_TOKEN_PURPOSE_EMAIL_VERIFICATION, EMAIL_VERIFICATION_TTL)
+	if err != nil {
+		return err
+	}
+
+	err = uc.sendVerificationEmail(ctx, *account, token)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (uc AuthUsecase) RefreshToken(ctx context.Context, refreshToken string) (*helper.JWTWrapper, error) {
+	claims, err := uc.jwtHelper.ParseRefreshToken(refreshToken)
 	if err != nil {
 		//including expiration error
 		//so, no need further check for token expiration
 		//just handle in auth delivery
 		return nil, err
 	}
-	mapClaims := token.Claims.(jwt.MapClaims)
 
 	//validate token in redis
-	rtRedis, _ := helper.RedisHelper.Get(mapClaims["refresh_uuid"].(string))
+	rtRedis, _ := uc.redisHelper.Get(ctx, claims.RefreshUUID)
 	if rtRedis == "" {
 		//token is expired
-		cerr := cerror.NewAndPrintWithTag("RTU00", errors.New("token_expired"), global.FRIENDLY_TOKEN_EXPIRED)
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "RTU00", errors.New("token_expired"), global.FRIENDLY_TOKEN_EXPIRED)
 		cerr.Type = cerror.TYPE_EXPIRED
 		return nil, cerr
 	}
 
 	//get account
-	accountID := mapClaims["account_id"].(string)
+	accountID := claims.AccountID
 	filter := domain.AccountFilter{AccountID: accountID}
-	account, err := uc.accountRepo.GetAccount(filter)
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
 	filterProfile := domain.ProfileFilter{AccountID: accountID}
-	profile, err := uc.profileRepo.GetProfile(filterProfile)
+	profile, err := uc.profileRepo.GetProfile(ctx, filterProfile)
 	if err != nil {
 		return nil, err
 	}
 
 	//create token
-	tokenPair, err := uc.createTokenPair(*account, *profile)
+	tokenPair, err := uc.createTokenPair(ctx, *account, *profile)
 	if err != nil {
 		return nil, err
 	}
 	return tokenPair, nil
 }
 
-func (uc AuthUsecase) VerifyEmail(token string) error {
-	jwtHelper := helper.JWTHelper{}
-	parsedToken, err := jwtHelper.ParseToken(token)
+func (uc AuthUsecase) VerifyEmail(ctx context.Context, token string) error {
+	accountToken, err := uc.consumeAccountToken(ctx, domain.ACCOUNT_TOKEN_PURPOSE_EMAIL_VERIFICATION, token)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("VEA00", err, global.FRIENDLY_INVALID_TOKEN)
+		return err
 	}
-	payload := parsedToken.Claims.(jwt.MapClaims)
 
-	expire := payload["exp"].(float64)
-	email := payload["email"].(string)
+	//verify email
+	err = uc.accountRepo.UpdateIsVerified(ctx, accountToken.AccountID, true)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (uc AuthUsecase) ResetPassword(ctx context.Context, email string, client domain.ClientInfo) error {
+	filter := domain.AccountFilter{Email: email}
+	account, _ := uc.accountRepo.GetAccount(ctx, filter)
+	if account != nil {
+		if !account.IsVerified {
+			cerr := cerror.NewAndPrintWithTagContext(ctx, "RPA01", fmt.Errorf("email %s has not been verified", account.Email), global.FRIENDLY_EMAIL_NOT_VERIFIED)
+			return cerr
+		}
+
+		token, err := uc.createAccountToken(ctx, *account, domain.ACCOUNT_TOKEN_PURPOSE_PASSWORD_RESET, PASSWORD_RESET_TTL)
+		if err != nil {
+			return err
+		}
+
+		data := map[string]interface{}{
+			"Link":           uc.generateResetPasswordUrl(token),
+			"ExpiresInHours": int(PASSWORD_RESET_TTL.Hours()),
+		}
+		to := []string{email}
+		err = uc.mailHelper.SendMail(ctx, to, uc.accountLocale(ctx, *account), global.EMAIL_TEMPLATE_RESET_PASSWORD, data)
+		if err != nil {
+			return err
+		}
+
+		uc.notifySecurityEvent(ctx, *account, securityEventPasswordReset, client)
+		return nil
+	}
 
-	//start convert unix time to time
-	unixInt := int64(expire)
-	expTime := time.Unix(unixInt, 0)
+	err := fmt.Errorf("email %s is not found", email)
+	cerr := cerror.NewAndPrintWithTagContext(ctx, "RPA00", err, "")
+	cerr.Type = cerror.TYPE_NOT_FOUND
+	return cerr
+}
 
-	if time.Now().After(expTime) {
-		return cerror.NewAndPrintWithTag("VEA02", err, global.FRIENDLY_TOKEN_EXPIRED)
+func (uc AuthUsecase) ChangePassword(ctx context.Context, token, password string, client domain.ClientInfo) error {
+	//the token is only looked up here, a password that is rejected must not use it up
+	accountToken, err := uc.accountTokenRepo.GetAccountToken(ctx, domain.ACCOUNT_TOKEN_PURPOSE_PASSWORD_RESET, uc.hashAccountToken(token))
+	if err != nil {
+		return err
 	}
-	//end convert unix time to time
 
 	//get account
-	filter := domain.AccountFilter{Email: email}
-	account, err := uc.accountRepo.GetAccount(filter)
+	filter := domain.AccountFilter{AccountID: accountToken.AccountID}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "CPW02", err, global.FRIENDLY_INVALID_EMAIL)
+		cerr.Type = cerror.TYPE_NOT_FOUND
+		return cerr
+	}
+
+	profile, _ := uc.profileRepo.GetProfile(ctx, domain.ProfileFilter{AccountID: account.AccountID})
+	var fullName string
+	if profile != nil {
+		fullName = profile.FullName
+	}
+
+	err = uc.checkPasswordPolicy(password, account.Email, fullName)
 	if err != nil {
 		return err
 	}
 
-	//verify email
-	err = uc.accountRepo.UpdateIsVerified(account.AccountID, true)
+	_, err = uc.consumeAccountToken(ctx, domain.ACCOUNT_TOKEN_PURPOSE_PASSWORD_RESET, token)
+	if err != nil {
+		return err
+	}
+
+	err = uc.updatePassword(ctx, *account, password)
+	if err != nil {
+		return err
+	}
+
+	//whoever knew the old password may still hold a session
+	err = uc.revokeSessions(ctx, account.AccountID)
 	if err != nil {
 		return err
 	}
 
+	uc.notifySecurityEvent(ctx, *account, securityEventPasswordChanged, client)
 	return nil
 }
 
-func (uc AuthUsecase) ResetPassword(email string) error {
-	claims := jwt.MapClaims{}
-	claims["email"] = email
-	claims["exp"] = time.Now().Add(24 * time.Hour).Unix()
+func (uc AuthUsecase) SignOut(ctx context.Context, accessToken *helper.AccessTokenClaims, refreshToken *helper.RefreshTokenClaims) error {
+	if accessToken != nil {
+		err := uc.redisHelper.Delete(ctx, accessToken.AccessUUID)
+		if err != nil {
+			return cerror.NewAndPrintWithTagContext(ctx, "SOU00", err, global.FRIENDLY_MESSAGE)
+		}
+	}
 
-	jwtHelper := helper.JWTHelper{}
-	token, err := jwtHelper.CreateToken(claims)
+	err := uc.redisHelper.Delete(ctx, refreshToken.RefreshUUID)
 	if err != nil {
-		return err
+		return cerror.NewAndPrintWithTagContext(ctx, "SOU01", err, global.FRIENDLY_MESSAGE)
 	}
 
+	sessionKey := ACCOUNT_SESSION_KEY + refreshToken.AccountID
+	if accessToken != nil {
+		err = uc.redisHelper.SRem(ctx, sessionKey, accessToken.AccessUUID, refreshToken.RefreshUUID)
+	} else {
+		err = uc.redisHelper.SRem(ctx, sessionKey, refreshToken.RefreshUUID)
+	}
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "SOU02", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
+
+// RevokeSessions signs the account with the given email out everywhere.
+func (uc AuthUsecase) RevokeSessions(ctx context.Context, email string) error {
 	filter := domain.AccountFilter{Email: email}
-	account, err := uc.accountRepo.GetAccount(filter)
-	if account != nil {
-		if !account.IsVerified {
-			cerr := cerror.NewAndPrintWithTag("RPA01", fmt.Errorf("email %s has not been verified", account.Email), global.FRIENDLY_EMAIL_NOT_VERIFIED)
-			return cerr
-		}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return uc.revokeSessions(ctx, account.AccountID)
+}
 
-		var updateTokenInput domain.Account
-		updateTokenInput.AccountID = account.AccountID
-		updateTokenInput.PasswordToken = token
-		err := uc.accountRepo.UpdatePasswordToken(updateTokenInput)
+// revokeSessions removes every access and refresh token issued to the account.
+func (uc AuthUsecase) revokeSessions(ctx context.Context, accountID string) error {
+	sessionKey := ACCOUNT_SESSION_KEY + accountID
+	tokenUUIDs, err := uc.redisHelper.SMembers(ctx, sessionKey)
+	if err != nil {
+		return err
+	}
+
+	for _, tokenUUID := range tokenUUIDs {
+		err = uc.redisHelper.Delete(ctx, tokenUUID)
 		if err != nil {
 			return err
 		}
+	}
 
-		url := uc.generateResetPasswordUrl(token)
+	return uc.redisHelper.Delete(ctx, sessionKey)
+}
 
-		msg := fmt.Sprintf(global.RESET_PASSWORD_TEMPLATE, url)
-		to := []string{email}
-		subject := config.Config.ResetPassword.Subject
-		err = uc.mailHelper.SendMail(to, subject, msg)
+// VerifyAccount marks the account with the given email as verified without a verification link.
+func (uc AuthUsecase) VerifyAccount(ctx context.Context, email string) error {
+	filter := domain.AccountFilter{Email: email}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return uc.accountRepo.UpdateIsVerified(ctx, account.AccountID, true)
+}
+
+// SetPassword replaces the password of the account with the given email without a reset link,
+// and signs the account out everywhere.
+func (uc AuthUsecase) SetPassword(ctx context.Context, email, password string, client domain.ClientInfo) error {
+	filter := domain.AccountFilter{Email: email}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var fullName string
+	profile, err := uc.profileRepo.GetProfile(ctx, domain.ProfileFilter{AccountID: account.AccountID})
+	if err == nil {
+		fullName = profile.FullName
+	}
+
+	err = uc.checkPasswordPolicy(password, account.Email, fullName)
+	if err != nil {
+		return err
+	}
+
+	err = uc.updatePassword(ctx, *account, password)
+	if err != nil {
+		return err
+	}
+
+	err = uc.revokeSessions(ctx, account.AccountID)
+	if err != nil {
+		return err
+	}
+
+	uc.notifySecurityEvent(ctx, *account, securityEventPasswordChanged, client)
+	return nil
+}
+
+// DeleteAccount removes the account together with everything it owns. The password, and the
+// second factor when enabled, are asked again so a stolen session cannot delete the account.
+func (uc AuthUsecase) DeleteAccount(ctx context.Context, accountID, password, code string) error {
+	filter := domain.AccountFilter{AccountID: accountID}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	ok, err := uc.passwordHasher.Verify(password, account.Password, account.Salt)
+	if !ok || err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "DAU00",
+			errors.New("incorrect password for email :"+account.Email),
+			global.FRIENDLY_INVALID_PASSWORD)
+		cerr.Type = cerror.TYPE_UNAUTHORIZED
+		return cerr
+	}
+
+	if account.IsTOTPEnabled {
+		err = uc.verifySecondFactor(ctx, *account, code)
 		if err != nil {
 			return err
 		}
+	}
+
+	var imageURLs []string
+	err = uc.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		imageURLs, err = uc.postRepo.GetPostImageURLs(ctx, accountID)
+		if err != nil {
+			return err
+		}
+
+		for _, imageURL := range imageURLs {
+			err = uc.imageRepo.DeleteImage(ctx, domain.Image{ImageURL: imageURL})
+			if err != nil {
+				return err
+			}
+		}
+
+		//rows referencing the account go first because of the foreign keys
+		deletes := []func(context.Context, string) error{
+			uc.postRepo.DeletePosts,
+			uc.recoveryCodeRepo.DeleteRecoveryCodes,
+			uc.accountTokenRepo.DeleteAccountTokens,
+			uc.accountDeviceRepo.DeleteAccountDevices,
+			uc.externalIdentityRepo.DeleteExternalIdentities,
+			uc.profileRepo.DeleteProfile,
+			uc.accountRepo.DeleteAccount,
+		}
+		for _, deleteRows := range deletes {
+			err = deleteRows(ctx, accountID)
+			if err != nil {
+				return err
+			}
+		}
 
 		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	err = fmt.Errorf("email %s is not found", email)
-	cerr := cerror.NewAndPrintWithTag("RPA00", err, "")
-	cerr.Type = cerror.TYPE_NOT_FOUND
-	return cerr
+	//files cannot be rolled back, so they are only removed once the rows are gone
+	for _, imageURL := range imageURLs {
+		err = uc.imageRepo.DeleteImageFile(domain.Image{ImageURL: imageURL})
+		if err != nil {
+			logger.WithTag(ctx, "DAU01").WithError(err).Warn("unable to remove image file ", imageURL)
+		}
+	}
+
+	err = uc.revokeSessions(ctx, accountID)
+	if err != nil {
+		logger.WithTag(ctx, "DAU02").WithError(err).Warn("unable to revoke sessions of ", account.Email)
+	}
+
+	return nil
 }
 
-func (uc AuthUsecase) ChangePassword(token, password string) error {
-	jwtHelper := helper.JWTHelper{}
-	parsedToken, err := jwtHelper.ParseToken(token)
+func (uc AuthUsecase) EnrollTOTP(ctx context.Context, accountID string) (*domain.TOTPEnrollment, error) {
+	filter := domain.AccountFilter{AccountID: accountID}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("CPW00", err, global.FRIENDLY_INVALID_TOKEN)
+		return nil, err
 	}
-	payload := parsedToken.Claims.(jwt.MapClaims)
 
-	expire := payload["exp"].(float64)
-	email := payload["email"].(string)
+	if account.IsTOTPEnabled {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "ETU00", fmt.Errorf("totp is already enabled for %s", account.Email), global.FRIENDLY_TOTP_ALREADY_ENABLED)
+		cerr.Type = cerror.TYPE_BAD_REQUEST
+		return nil, cerr
+	}
 
-	//start convert unix time to time
-	unixInt := int64(expire)
-	expTime := time.Unix(unixInt, 0)
+	totpHelper := helper.TOTPHelper{}
+	secret, err := totpHelper.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
 
-	if time.Now().After(expTime) {
-		return cerror.NewAndPrintWithTag("CPW01", err, global.FRIENDLY_TOKEN_EXPIRED)
+	//the secret is stored disabled until the first code is confirmed
+	account.TOTPSecret = secret
+	account.IsTOTPEnabled = false
+	err = uc.accountRepo.UpdateTOTP(ctx, *account)
+	if err != nil {
+		return nil, err
 	}
-	//end convert unix time to time
 
-	//get account
-	filter := domain.AccountFilter{Email: email}
-	account, err := uc.accountRepo.GetAccount(filter)
+	enrollment := &domain.TOTPEnrollment{
+		Secret: secret,
+		URI:    totpHelper.GenerateURI(uc.config.TOTPIssuer, account.Email, secret),
+	}
+	return enrollment, nil
+}
+
+func (uc AuthUsecase) ConfirmTOTP(ctx context.Context, accountID, code string, client domain.ClientInfo) ([]string, error) {
+	filter := domain.AccountFilter{AccountID: accountID}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("CPW02", err, global.FRIENDLY_INVALID_EMAIL)
-		cerr.Type = cerror.TYPE_NOT_FOUND
-		return cerr
+		return nil, err
 	}
 
-	if token != account.PasswordToken {
-		errMsg := fmt.Sprintf("token is different from token from database. email : %s", account.Email)
-		cerr := cerror.NewAndPrintWithTag("CPW03", errors.New(errMsg), global.FRIENDLY_INVALID_TOKEN)
-		return cerr
+	if account.IsTOTPEnabled {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "CTU00", fmt.Errorf("totp is already enabled for %s", account.Email), global.FRIENDLY_TOTP_ALREADY_ENABLED)
+		cerr.Type = cerror.TYPE_BAD_REQUEST
+		return nil, cerr
+	}
+
+	if account.TOTPSecret == "" {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "CTU01", fmt.Errorf("totp is not enrolled for %s", account.Email), global.FRIENDLY_TOTP_NOT_ENROLLED)
+		cerr.Type = cerror.TYPE_BAD_REQUEST
+		return nil, cerr
 	}
 
-	account.Salt, err = uc.generateSalt()
+	err = uc.verifyTOTPCode(ctx, *account, code)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, hashedCodes, err := uc.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.recoveryCodeRepo.ReplaceRecoveryCodes(ctx, account.AccountID, hashedCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	account.IsTOTPEnabled = true
+	err = uc.accountRepo.UpdateTOTP(ctx, *account)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.notifySecurityEvent(ctx, *account, securityEventTwoFactorEnabled, client)
+	return recoveryCodes, nil
+}
+
+func (uc AuthUsecase) DisableTOTP(ctx context.Context, accountID, code string, client domain.ClientInfo) error {
+	filter := domain.AccountFilter{AccountID: accountID}
+	account, err := uc.accountRepo.GetAccount(ctx, filter)
 	if err != nil {
 		return err
 	}
 
-	account.Password, err = uc.hashPassword([]byte(password), account.Salt)
+	if !account.IsTOTPEnabled {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "DTU00", fmt.Errorf("totp is not enabled for %s", account.Email), global.FRIENDLY_TOTP_NOT_ENABLED)
+		cerr.Type = cerror.TYPE_BAD_REQUEST
+		return cerr
+	}
+
+	err = uc.verifySecondFactor(ctx, *account, code)
 	if err != nil {
 		return err
 	}
 
-	err = uc.accountRepo.UpdateSaltAndPassword(*account)
+	account.TOTPSecret = ""
+	account.IsTOTPEnabled = false
+	err = uc.accountRepo.UpdateTOTP(ctx, *account)
 	if err != nil {
 		return err
 	}
 
-	var updateTokenInput domain.Account
-	updateTokenInput.AccountID = account.AccountID
-	updateTokenInput.PasswordToken = ""
-	err = uc.accountRepo.UpdatePasswordToken(updateTokenInput)
+	err = uc.recoveryCodeRepo.DeleteRecoveryCodes(ctx, account.AccountID)
 	if err != nil {
 		return err
 	}
 
+	uc.notifySecurityEvent(ctx, *account, securityEventTwoFactorDisabled, client)
 	return nil
 }
 
-func (uc AuthUsecase) SignOut(accessToken, refreshToken *jwt.Token) error {
-	atClaims := accessToken.Claims.(jwt.MapClaims)
-	atUUID := atClaims["access_uuid"].(string)
-	err := helper.RedisHelper.Delete(atUUID)
+func (uc AuthUsecase) createLoginChallenge(ctx context.Context, account domain.Account) (*domain.LoginChallenge, error) {
+	challenge := new(domain.LoginChallenge)
+	challenge.ChallengeToken = uuid.New().String()
+	challenge.ExpTime = time.Now().Add(LOGIN_CHALLENGE_TTL)
+
+	err := uc.redisHelper.Set(ctx, LOGIN_CHALLENGE_KEY+challenge.ChallengeToken, account.AccountID, challenge.ExpTime.Unix())
 	if err != nil {
-		return cerror.NewAndPrintWithTag("SOU00", err, global.FRIENDLY_MESSAGE)
+		return nil, err
 	}
 
-	rtClaims := refreshToken.Claims.(jwt.MapClaims)
-	rtUUID := rtClaims["refresh_uuid"].(string)
-	err = helper.RedisHelper.Delete(rtUUID)
+	return challenge, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or one of the unused recovery codes.
+func (uc AuthUsecase) verifySecondFactor(ctx context.Context, account domain.Account, code string) error {
+	err := uc.verifyTOTPCode(ctx, account, code)
+	if err == nil {
+		return nil
+	}
+
+	err = uc.recoveryCodeRepo.UseRecoveryCode(ctx, account.AccountID, uc.hashRecoveryCode(code))
 	if err != nil {
-		return cerror.NewAndPrintWithTag("SOU01", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "VSF00", fmt.Errorf("invalid second factor for %s", account.Email), global.FRIENDLY_INVALID_OTP)
+		cerr.Type = cerror.TYPE_UNAUTHORIZED
+		return cerr
 	}
 
 	return nil
 }
 
-func (uc AuthUsecase) generateSalt() ([]byte, error) {
-	salt := make([]byte, SALT_BYTES)
-	_, err := io.ReadFull(rand.Reader, salt)
+func (uc AuthUsecase) verifyTOTPCode(ctx context.Context, account domain.Account, code string) error {
+	totpHelper := helper.TOTPHelper{}
+	step, ok := totpHelper.Validate(code, account.TOTPSecret, time.Now())
+	if !ok {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "VTC00", fmt.Errorf("invalid totp code for %s", account.Email), global.FRIENDLY_INVALID_OTP)
+		cerr.Type = cerror.TYPE_UNAUTHORIZED
+		return cerr
+	}
+
+	//a code is valid for a whole time step (and the skew around it), so remember
+	//which step has been used to stop the same code from being replayed. Taking the
+	//step with SetNX lets only one of two concurrent logins with the same code pass.
+	usedKey := fmt.Sprintf("%s%s:%d", TOTP_USED_KEY, account.AccountID, step)
+	usedExp := time.Unix((step+helper.TOTP_SKEW+1)*helper.TOTP_PERIOD, 0)
+	isFirstUse, err := uc.redisHelper.SetNX(ctx, usedKey, "1", usedExp.Unix())
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("GSA00", err, global.FRIENDLY_MESSAGE)
+		return err
+	}
+
+	if !isFirstUse {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "VTC01", fmt.Errorf("totp code replayed for %s", account.Email), global.FRIENDLY_INVALID_OTP)
+		cerr.Type = cerror.TYPE_UNAUTHORIZED
+		return cerr
 	}
-	return salt, nil
+
+	return nil
 }
 
-func (uc AuthUsecase) hashPassword(password, salt []byte) (string, error) {
-	var saltedPassword []byte
-	saltedPassword = append(saltedPassword, password...)
-	saltedPassword = append(saltedPassword, salt...)
+// generateRecoveryCodes returns the plain codes, which are shown to the user once,
+// and their hashed form which is the only thing stored.
+func (uc AuthUsecase) generateRecoveryCodes() ([]string, []domain.RecoveryCode, error) {
+	var (
+		codes       []string
+		hashedCodes []domain.RecoveryCode
+	)
+
+	for i := 0; i < RECOVERY_CODE_COUNT; i++ {
+		raw := make([]byte, RECOVERY_CODE_BYTES)
+		_, err := io.ReadFull(rand.Reader, raw)
+		if err != nil {
+			return nil, nil, cerror.NewAndPrintWithTag("GRC00", err, global.FRIENDLY_MESSAGE)
+		}
+
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+		code := encoded[:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:]
+
+		codes = append(codes, code)
+		hashedCodes = append(hashedCodes, domain.RecoveryCode{CodeHash: uc.hashRecoveryCode(code)})
+	}
+
+	return codes, hashedCodes, nil
+}
 
-	hash, err := bcrypt.GenerateFromPassword(saltedPassword, bcrypt.MinCost)
+// hashRecoveryCode keys the hash with RecoveryCodeSecret, so the stored hashes can not be
+// brute forced without it.
+func (uc AuthUsecase) hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	mac := hmac.New(sha256.New, []byte(uc.config.RecoveryCodeSecret))
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (uc AuthUsecase) generateRandomToken(size int) (string, error) {
+	raw := make([]byte, size)
+	_, err := io.ReadFull(rand.Reader, raw)
 	if err != nil {
-		return "", cerror.NewAndPrintWithTag("HPA00", err, global.FRIENDLY_MESSAGE)
+		return "", cerror.NewAndPrintWithTag("GRT00", err, global.FRIENDLY_MESSAGE)
 	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func (uc AuthUsecase) isNotFound(err error) bool {
+	cerr, ok := err.(cerror.Error)
+	return ok && cerr.Err == sql.ErrNoRows
+}
 
-	return string(hash), nil
+func (uc AuthUsecase) checkPasswordPolicy(password string, userInputs ...string) error {
+	violations := uc.passwordPolicy.Check(password, userInputs...)
+	if len(violations) > 0 {
+		cerr := cerror.NewAndPrintWithTag("CPP00", fmt.Errorf("password violates %d policy rules", len(violations)), global.FRIENDLY_PASSWORD_POLICY)
+		cerr.Type = cerror.TYPE_BAD_REQUEST
+		cerr.Details = violations
+		return cerr
+	}
+	return nil
 }
 
-func (uc AuthUsecase) comparePassword(passwordInput, salt, storedPassword []byte) (error, bool) {
-	var saltedPassword []byte
-	saltedPassword = append(saltedPassword, passwordInput...)
-	saltedPassword = append(saltedPassword, salt...)
+// updatePassword stores a new hash of password. The salt is part of the hash now,
+// so the legacy salt column is cleared.
+func (uc AuthUsecase) updatePassword(ctx context.Context, account domain.Account, password string) error {
+	var err error
+	account.Password, err = uc.passwordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	account.Salt = nil
+	return uc.accountRepo.UpdateSaltAndPassword(ctx, account)
+}
+
+// createAccountToken stores the hash of a new random token for the given purpose and returns
+// the token itself, which is only ever sent to the user.
+func (uc AuthUsecase) createAccountToken(ctx context.Context, account domain.Account, purpose string, ttl time.Duration) (string, error) {
+	token, err := uc.generateRandomToken(ACCOUNT_TOKEN_BYTES)
+	if err != nil {
+		return "", err
+	}
+
+	accountToken := domain.AccountToken{
+		AccountID: account.AccountID,
+		Purpose:   purpose,
+		TokenHash: uc.hashAccountToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	err = uc.accountTokenRepo.ReplaceAccountToken(ctx, accountToken)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
 
-	err := bcrypt.CompareHashAndPassword(storedPassword, saltedPassword)
+// consumeAccountToken uses up the token, so it cannot be used again whether or not it has expired.
+func (uc AuthUsecase) consumeAccountToken(ctx context.Context, purpose, token string) (*domain.AccountToken, error) {
+	accountToken, err := uc.accountTokenRepo.ConsumeAccountToken(ctx, purpose, uc.hashAccountToken(token))
 	if err != nil {
-		return cerror.NewAndPrintWithTag("CPA00", err, global.FRIENDLY_INVALID_USNME_PASSWORD), false
+		return nil, err
+	}
+
+	if time.Now().After(accountToken.ExpiresAt) {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "CAU00", fmt.Errorf("%s token of %s has expired", purpose, accountToken.AccountID), global.FRIENDLY_TOKEN_EXPIRED)
+		cerr.Type = cerror.TYPE_EXPIRED
+		return nil, cerr
 	}
 
-	return nil, true
+	return accountToken, nil
+}
+
+func (uc AuthUsecase) hashAccountToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func (uc AuthUsecase) generateEmailConfirmationUrl(account domain.Account) string {
-	url := fmt.Sprintf("%s/email_confirmation?token=%s", config.Config.FEHost, account.EmailToken)
+func (uc AuthUsecase) sendVerificationEmail(ctx context.Context, account domain.Account, token string) error {
+	data := map[string]interface{}{
+		"Link":             uc.generateEmailConfirmationUrl(token),
+		"ExpiresInMinutes": int(EMAIL_VERIFICATION_TTL.Minutes()),
+	}
+	to := []string{account.Email}
+	return uc.mailHelper.SendMail(ctx, to, uc.accountLocale(ctx, account), global.EMAIL_TEMPLATE_VERIFY_EMAIL, data)
+}
+
+// accountLocale returns the preferred language of the account, the default locale is used
+// when the profile can not be read.
+func (uc AuthUsecase) accountLocale(ctx context.Context, account domain.Account) string {
+	profile, err := uc.profileRepo.GetProfile(ctx, domain.ProfileFilter{AccountID: account.AccountID})
+	if err != nil {
+		return global.DEFAULT_LOCALE
+	}
+	return profile.Locale
+}
+
+func (uc AuthUsecase) generateEmailConfirmationUrl(token string) string {
+	url := fmt.Sprintf("%s/email_confirmation?token=%s", uc.config.FEHost, token)
 	return url
 }
 
 func (uc AuthUsecase) generateResetPasswordUrl(token string) string {
-	url := fmt.Sprintf("%s/change_password?token=%s", config.Config.FEHost, token)
+	url := fmt.Sprintf("%s/change_password?token=%s", uc.config.FEHost, token)
 	return url
 }
 
-func (uc AuthUsecase) createTokenPair(account domain.Account, profile domain.Profile) (*helper.JWTWrapper, error) {
-	accessTokenClaims := jwt.MapClaims{}
-	accessTokenClaims["authorized"] = true
-	accessTokenClaims["account_id"] = account.AccountID
-	accessTokenClaims["access_uuid"] = uuid.New().String()
-	accessTokenClaims["email"] = account.Email
-	accessTokenClaims["exp"] = time.Now().Add(15 * time.Minute).Unix()
-	accessTokenClaims["full_name"] = profile.FullName
+func (uc AuthUsecase) generateMagicLinkUrl(token string) string {
+	url := fmt.Sprintf("%s/magic_link?token=%s", uc.config.FEHost, token)
+	return url
+}
 
-	refreshTokenClaims := jwt.MapClaims{}
-	refreshTokenClaims["account_id"] = account.AccountID
-	refreshTokenClaims["refresh_uuid"] = uuid.New().String()
+func (uc AuthUsecase) createTokenPair(ctx context.Context, account domain.Account, profile domain.Profile) (*helper.JWTWrapper, error) {
+	accessTokenClaims := helper.AccessTokenClaims{
+		TokenClaims: uc.jwtHelper.NewTokenClaims(helper.TOKEN_TYPE_ACCESS, time.Now().Add(15*time.Minute)),
+		Authorized:  true,
+		AccountID:   account.AccountID,
+		AccessUUID:  uuid.New().String(),
+		Email:       account.Email,
+		FullName:    profile.FullName,
+	}
 
 	rtExp := time.Now().Add(1 * time.Hour)
-	refreshTokenClaims["exp"] = rtExp.Unix()
+	refreshTokenClaims := helper.RefreshTokenClaims{
+		TokenClaims: uc.jwtHelper.NewTokenClaims(helper.TOKEN_TYPE_REFRESH, rtExp),
+		AccountID:   account.AccountID,
+		RefreshUUID: uuid.New().String(),
+	}
 
-	jwtHelper := helper.JWTHelper{}
-	token, err := jwtHelper.CreateTokenPair(accessTokenClaims, refreshTokenClaims)
+	token, err := uc.jwtHelper.CreateTokenPair(accessTokenClaims, refreshTokenClaims)
 	if err != nil {
 		return nil, err
 	}
 	token.RefreshTokenExpTime = rtExp
 
-	err = helper.RedisHelper.Set(accessTokenClaims["access_uuid"].(string), token.AccessToken, accessTokenClaims["exp"].(int64))
+	err = uc.redisHelper.Set(ctx, accessTokenClaims.AccessUUID, token.AccessToken, accessTokenClaims.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.redisHelper.Set(ctx, refreshTokenClaims.RefreshUUID, token.RefreshToken, refreshTokenClaims.ExpiresAt)
 	if err != nil {
 		return nil, err
 	}
 
-	err = helper.RedisHelper.Set(refreshTokenClaims["refresh_uuid"].(string), token.RefreshToken, refreshTokenClaims["exp"].(int64))
+	//the tokens are also kept per account, so all sessions of an account can be revoked at once
+	err = uc.redisHelper.SAdd(ctx, ACCOUNT_SESSION_KEY+account.AccountID, refreshTokenClaims.ExpiresAt,
+		accessTokenClaims.AccessUUID, refreshTokenClaims.RefreshUUID)
 	if err != nil {
 		return nil, err
 	}
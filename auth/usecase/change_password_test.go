@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/helper"
+)
+
+const testResetToken = "reset-token"
+
+func TestChangePasswordRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+
+	jwtHelper, err := helper.NewJWTHelper(config.JWTConfig{Secret: "test-secret"}, "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passwordPolicy, err := helper.NewPasswordPolicy(config.PasswordPolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := domain.Account{AccountID: testAccountID, Email: "john@example.com", IsVerified: true}
+	profile := domain.Profile{AccountID: testAccountID, FullName: "John"}
+	uc := NewAuthUsecase(
+		fakeAccountRepo{account: account},
+		fakeProfileRepo{profile: profile},
+		nil,
+		fakeAccountTokenRepo{token: domain.AccountToken{
+			AccountID: testAccountID,
+			Purpose:   domain.ACCOUNT_TOKEN_PURPOSE_PASSWORD_RESET,
+			TokenHash: AuthUsecase{}.hashAccountToken(testResetToken),
+			ExpiresAt: time.Now().Add(PASSWORD_RESET_TTL),
+		}},
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		fakeMail{},
+		nil,
+		helper.NewPasswordHasher(config.PasswordHashConfig{Memory: 1024, Iterations: 1, Parallelism: 1}),
+		passwordPolicy,
+		newFakeRedis(),
+		jwtHelper,
+		AuthConfig{FEHost: "http://localhost"},
+		nil,
+	)
+
+	session, err := uc.(*AuthUsecase).createTokenPair(ctx, account, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = uc.RefreshToken(ctx, session.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken before the password change : %v", err)
+	}
+
+	err = uc.ChangePassword(ctx, testResetToken, "Quiet-Harbor-Lantern-1987", domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("ChangePassword : %v", err)
+	}
+
+	token, err := uc.RefreshToken(ctx, session.RefreshToken)
+	if cerr, ok := err.(cerror.Error); !ok || cerr.Tag != "RTU00" || token != nil {
+		t.Fatalf("RefreshToken after the password change returned %+v, error %v, want tag RTU00", token, err)
+	}
+}
+
+func (r fakeAccountRepo) UpdateSaltAndPassword(ctx context.Context, account domain.Account) error {
+	return nil
+}
+
+type fakeAccountTokenRepo struct {
+	domain.IAccountTokenRepository
+	token domain.AccountToken
+}
+
+func (r fakeAccountTokenRepo) GetAccountToken(ctx context.Context, purpose, tokenHash string) (*domain.AccountToken, error) {
+	if purpose != r.token.Purpose || tokenHash != r.token.TokenHash {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "TGT00", sql.ErrNoRows, "")
+	}
+	token := r.token
+	return &token, nil
+}
+
+func (r fakeAccountTokenRepo) ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (*domain.AccountToken, error) {
+	return r.GetAccountToken(ctx, purpose, tokenHash)
+}
+
+type fakeMail struct{}
+
+func (m fakeMail) SendMail(ctx context.Context, to []string, locale, templateName string, data interface{}) error {
+	return nil
+}
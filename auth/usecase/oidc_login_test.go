@@ -0,0 +1,446 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/helper"
+)
+
+const (
+	testOIDCProvider = "mock"
+	testClientID     = "mymoment"
+	testClientSecret = "secret"
+	testKID          = "mock-key"
+	testSubject      = "subject-1"
+	testAccountID    = "account-1"
+)
+
+// mockOIDCProvider serves discovery, JWKS and a token endpoint that answers with an ID token made
+// by claims from the nonce of the authorization request.
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu             sync.Mutex
+	nonce          string
+	codeChallenge  string
+	idTokenClaims  func(issuer, nonce string) jwt.MapClaims
+	tokenRequested bool
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &mockOIDCProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *mockOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 p.server.URL,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+func (p *mockOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": testKID,
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokenRequested = true
+
+	clientID, clientSecret, _ := r.BasicAuth()
+	if clientID != testClientID || clientSecret != testClientSecret {
+		http.Error(w, "invalid client", http.StatusUnauthorized)
+		return
+	}
+
+	//PKCE : the verifier sent now has to hash to the challenge of the authorization request
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(verifier[:]) != p.codeChallenge {
+		http.Error(w, "invalid code verifier", http.StatusBadRequest)
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, p.idTokenClaims(p.server.URL, p.nonce))
+	idToken.Header["kid"] = testKID
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
+
+// authorize stands in for the browser visiting the authorization URL : it keeps the nonce and
+// PKCE challenge of the request and returns the state the provider redirects back with.
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nonce = query.Get("nonce")
+	p.codeChallenge = query.Get("code_challenge")
+	p.tokenRequested = false
+
+	return query.Get("state")
+}
+
+func validIDTokenClaims(issuer, nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            issuer,
+		"aud":            testClientID,
+		"sub":            testSubject,
+		"nonce":          nonce,
+		"email":          "john@example.com",
+		"email_verified": true,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func TestLoginOIDC(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	uc := newOIDCTestUsecase(t, provider.server.URL)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		idTokenClaims func(issuer, nonce string) jwt.MapClaims
+		state         func(state string) string
+		wantTag       string
+	}{
+		{
+			name:          "valid id token",
+			idTokenClaims: validIDTokenClaims,
+		},
+		{
+			name: "nonce mismatch",
+			idTokenClaims: func(issuer, nonce string) jwt.MapClaims {
+				claims := validIDTokenClaims(issuer, nonce)
+				claims["nonce"] = "replayed-nonce"
+				return claims
+			},
+			wantTag: "EXO06",
+		},
+		{
+			name: "audience of another client",
+			idTokenClaims: func(issuer, nonce string) jwt.MapClaims {
+				claims := validIDTokenClaims(issuer, nonce)
+				claims["aud"] = "another-client"
+				return claims
+			},
+			wantTag: "EXO05",
+		},
+		{
+			name: "issuer of another provider",
+			idTokenClaims: func(issuer, nonce string) jwt.MapClaims {
+				claims := validIDTokenClaims(issuer, nonce)
+				claims["iss"] = "https://issuer.example.com"
+				return claims
+			},
+			wantTag: "EXO04",
+		},
+		{
+			name:          "unknown state",
+			idTokenClaims: validIDTokenClaims,
+			state:         func(state string) string { return "forged-state" },
+			wantTag:       "LOU00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.idTokenClaims = tt.idTokenClaims
+
+			authURL, state, err := uc.OIDCAuthURL(ctx, testOIDCProvider)
+			if err != nil {
+				t.Fatalf("OIDCAuthURL : %v", err)
+			}
+
+			redirectState := provider.authorize(t, authURL)
+			if redirectState != state {
+				t.Fatalf("auth url state %q, want %q", redirectState, state)
+			}
+			if tt.state != nil {
+				redirectState = tt.state(redirectState)
+			}
+
+			token, challenge, err := uc.LoginOIDC(ctx, testOIDCProvider, redirectState, "code", domain.ClientInfo{IP: "127.0.0.1", UserAgent: "test"})
+			if tt.wantTag != "" {
+				cerr, ok := err.(cerror.Error)
+				if !ok || cerr.Tag != tt.wantTag {
+					t.Fatalf("LoginOIDC error %v, want tag %s", err, tt.wantTag)
+				}
+				if token != nil {
+					t.Fatal("LoginOIDC returned a token with an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoginOIDC : %v", err)
+			}
+			if !provider.tokenRequested {
+				t.Fatal("the code was not exchanged at the token endpoint")
+			}
+			if challenge != nil || token == nil || token.AccessToken == "" || token.RefreshToken == "" {
+				t.Fatalf("LoginOIDC returned token %+v and challenge %+v", token, challenge)
+			}
+
+			//the state is single use
+			_, _, err = uc.LoginOIDC(ctx, testOIDCProvider, redirectState, "code", domain.ClientInfo{})
+			if cerr, ok := err.(cerror.Error); !ok || cerr.Tag != "LOU00" {
+				t.Fatalf("replayed state error %v, want tag LOU00", err)
+			}
+		})
+	}
+}
+
+// newOIDCTestUsecase builds an AuthUsecase with the real OIDC and JWT helpers, an account that is
+// already linked to testSubject and fakes for the rest.
+func newOIDCTestUsecase(t *testing.T, issuer string) domain.IAuthUsecase {
+	oidcHelper := helper.NewOIDCHelper(map[string]config.OIDCProviderConfig{
+		testOIDCProvider: {
+			Issuer:       issuer,
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			RedirectURL:  "http://localhost/api/auth/oidc/mock/callback",
+		},
+	})
+
+	jwtHelper, err := helper.NewJWTHelper(config.JWTConfig{Secret: "test-secret"}, "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := domain.Account{AccountID: testAccountID, Email: "john@example.com", IsVerified: true}
+	return NewAuthUsecase(
+		fakeAccountRepo{account: account},
+		fakeProfileRepo{profile: domain.Profile{AccountID: testAccountID, FullName: "John"}},
+		nil,
+		nil,
+		fakeAccountDeviceRepo{},
+		fakeExternalIdentityRepo{identity: domain.ExternalIdentity{AccountID: testAccountID, Provider: testOIDCProvider, Subject: testSubject}},
+		nil,
+		nil,
+		nil,
+		nil,
+		oidcHelper,
+		nil,
+		nil,
+		newFakeRedis(),
+		jwtHelper,
+		AuthConfig{FEHost: "http://localhost"},
+		nil,
+	)
+}
+
+// the fakes embed their interface, a call the test does not expect panics on the nil interface
+
+type fakeAccountRepo struct {
+	domain.IAccountRepository
+	account domain.Account
+}
+
+func (r fakeAccountRepo) GetAccount(ctx context.Context, filter domain.AccountFilter) (*domain.Account, error) {
+	if filter.AccountID != r.account.AccountID && filter.Email != r.account.Email {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "TGA00", sql.ErrNoRows, "")
+	}
+	account := r.account
+	return &account, nil
+}
+
+type fakeProfileRepo struct {
+	domain.IProfileRepository
+	profile domain.Profile
+}
+
+func (r fakeProfileRepo) GetProfile(ctx context.Context, filter domain.ProfileFilter) (*domain.Profile, error) {
+	profile := r.profile
+	return &profile, nil
+}
+
+type fakeAccountDeviceRepo struct {
+	domain.IAccountDeviceRepository
+}
+
+func (r fakeAccountDeviceRepo) HasAccountDevices(ctx context.Context, accountID string) (bool, error) {
+	return false, nil
+}
+
+func (r fakeAccountDeviceRepo) TouchAccountDevice(ctx context.Context, device domain.AccountDevice) (bool, error) {
+	return true, nil
+}
+
+type fakeExternalIdentityRepo struct {
+	domain.IExternalIdentityRepository
+	identity domain.ExternalIdentity
+}
+
+func (r fakeExternalIdentityRepo) GetExternalIdentity(ctx context.Context, filter domain.ExternalIdentityFilter) (*domain.ExternalIdentity, error) {
+	if filter.Provider != r.identity.Provider || filter.Subject != r.identity.Subject {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "TGE00", sql.ErrNoRows, "")
+	}
+	identity := r.identity
+	return &identity, nil
+}
+
+// fakeRedis keeps values in memory and ignores expiry.
+type fakeRedis struct {
+	mu     *sync.Mutex
+	values map[string]string
+	sets   map[string]map[string]bool
+}
+
+func newFakeRedis() fakeRedis {
+	return fakeRedis{mu: new(sync.Mutex), values: make(map[string]string), sets: make(map[string]map[string]bool)}
+}
+
+func (r fakeRedis) Set(ctx context.Context, key string, value interface{}, exp int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = toString(value)
+	return nil
+}
+
+func (r fakeRedis) Get(ctx context.Context, key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.values[key]
+	if !ok {
+		return "", cerror.NewAndPrintWithTagContext(ctx, "TRG00", sql.ErrNoRows, "")
+	}
+	return value, nil
+}
+
+func (r fakeRedis) Delete(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.values, key)
+	delete(r.sets, key)
+	return nil
+}
+
+func (r fakeRedis) Incr(ctx context.Context, key string, exp int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var value int64
+	json.Unmarshal([]byte(r.values[key]), &value)
+	value++
+	r.values[key] = toString(value)
+	return value, nil
+}
+
+func (r fakeRedis) GetAndDelete(ctx context.Context, key string) (string, error) {
+	value, err := r.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return value, r.Delete(ctx, key)
+}
+
+func (r fakeRedis) SetNX(ctx context.Context, key string, value interface{}, exp int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.values[key]; ok {
+		return false, nil
+	}
+	r.values[key] = toString(value)
+	return true, nil
+}
+
+func (r fakeRedis) SAdd(ctx context.Context, key string, exp int64, members ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sets[key] == nil {
+		r.sets[key] = make(map[string]bool)
+	}
+	for _, member := range members {
+		r.sets[key][member] = true
+	}
+	return nil
+}
+
+func (r fakeRedis) SMembers(ctx context.Context, key string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var members []string
+	for member := range r.sets[key] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (r fakeRedis) SRem(ctx context.Context, key string, members ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, member := range members {
+		delete(r.sets[key], member)
+	}
+	return nil
+}
+
+func (r fakeRedis) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r fakeRedis) Close() error {
+	return nil
+}
+
+func toString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
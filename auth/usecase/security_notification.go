@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pajri/personal-backend/adapter/logger"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+)
+
+const MAX_USER_AGENT_LENGTH = 512
+
+// securityEvent is something that happened to an account that its owner is told about by email.
+// Critical events are always sent, the others can be turned off in the profile.
+type securityEvent struct {
+	template string
+	critical bool
+}
+
+var (
+	securityEventNewDeviceLogin = securityEvent{
+		template: global.EMAIL_TEMPLATE_NEW_DEVICE_LOGIN,
+	}
+	securityEventPasswordChanged = securityEvent{
+		template: global.EMAIL_TEMPLATE_PASSWORD_CHANGED,
+		critical: true,
+	}
+	securityEventPasswordReset = securityEvent{
+		template: global.EMAIL_TEMPLATE_PASSWORD_RESET,
+	}
+	securityEventTwoFactorEnabled = securityEvent{
+		template: global.EMAIL_TEMPLATE_TWO_FACTOR_ENABLED,
+		critical: true,
+	}
+	securityEventTwoFactorDisabled = securityEvent{
+		template: global.EMAIL_TEMPLATE_TWO_FACTOR_DISABLED,
+		critical: true,
+	}
+)
+
+// notifySecurityEvent emails the account owner. Failing to notify must not fail the action
+// that was already done, so errors are only logged.
+func (uc AuthUsecase) notifySecurityEvent(ctx context.Context, account domain.Account, event securityEvent, client domain.ClientInfo) {
+	if !event.critical {
+		profile, err := uc.profileRepo.GetProfile(ctx, domain.ProfileFilter{AccountID: account.AccountID})
+		if err == nil && !profile.NotifySecurityEvents {
+			return
+		}
+	}
+
+	ip, device := client.IP, client.UserAgent
+	if ip == "" {
+		ip = "unknown"
+	}
+	if device == "" {
+		device = "unknown"
+	}
+
+	data := map[string]interface{}{
+		"Time":   time.Now().UTC().Format(time.RFC1123),
+		"IP":     ip,
+		"Device": device,
+	}
+
+	err := uc.mailHelper.SendMail(ctx, []string{account.Email}, uc.accountLocale(ctx, account), event.template, data)
+	if err != nil {
+		logger.WithTag(ctx, "NSE00").WithError(err).Warn("unable to send security notification to ", account.Email)
+	}
+}
+
+// recordLogin remembers the device of a successful login and notifies the owner when the device
+// is new. The first device of an account is remembered without a notification.
+func (uc AuthUsecase) recordLogin(ctx context.Context, account domain.Account, client domain.ClientInfo) {
+	hasDevices, err := uc.accountDeviceRepo.HasAccountDevices(ctx, account.AccountID)
+	if err != nil {
+		return
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > MAX_USER_AGENT_LENGTH {
+		userAgent = userAgent[:MAX_USER_AGENT_LENGTH]
+	}
+
+	sum := sha256.Sum256([]byte(client.UserAgent))
+	device := domain.AccountDevice{
+		AccountID:  account.AccountID,
+		DeviceHash: hex.EncodeToString(sum[:]),
+		UserAgent:  userAgent,
+		LastIP:     client.IP,
+		LastSeenAt: time.Now(),
+	}
+
+	isNew, err := uc.accountDeviceRepo.TouchAccountDevice(ctx, device)
+	if err != nil {
+		return
+	}
+
+	if isNew && hasDevices {
+		uc.notifySecurityEvent(ctx, account, securityEventNewDeviceLogin, client)
+	}
+}
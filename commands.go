@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// CLI_CLIENT is shown as the IP address in the security notifications of commands.
+const CLI_CLIENT = "command line"
+
+func runCreateUser(args []string) error {
+	flags := flag.NewFlagSet("create-user", flag.ExitOnError)
+	email := flags.String("email", "", "email of the account (required)")
+	fullName := flags.String("name", "", "full name of the account (required)")
+	locale := flags.String("locale", global.DEFAULT_LOCALE, "language of the emails sent to the account")
+	verified := flags.Bool("verified", false, "create the account verified instead of emailing a verification link")
+	flags.Parse(args)
+
+	if *email == "" || *fullName == "" {
+		flags.Usage()
+		return errors.New("email and name are required")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	account := domain.Account{Email: *email, Password: password, IsVerified: *verified}
+	profile := domain.Profile{FullName: *fullName, Locale: *locale}
+	createdAccount, _, err := a.AuthUsecase.SignUp(context.Background(), account, profile)
+	if err != nil {
+		return commandError(err)
+	}
+
+	fmt.Println("created account", createdAccount.AccountID)
+	if !createdAccount.IsVerified {
+		fmt.Println("a verification email is queued, it is sent by a running server")
+	}
+	return nil
+}
+
+func runVerifyUser(args []string) error {
+	flags := flag.NewFlagSet("verify-user", flag.ExitOnError)
+	email := flags.String("email", "", "email of the account (required)")
+	flags.Parse(args)
+
+	if *email == "" {
+		flags.Usage()
+		return errors.New("email is required")
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	err = a.AuthUsecase.VerifyAccount(context.Background(), *email)
+	if err != nil {
+		return commandError(err)
+	}
+
+	fmt.Println("verified", *email)
+	return nil
+}
+
+func runResetPassword(args []string) error {
+	flags := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	email := flags.String("email", "", "email of the account (required)")
+	sendLink := flags.Bool("send-link", false, "email a reset link instead of setting the password")
+	flags.Parse(args)
+
+	if *email == "" {
+		flags.Usage()
+		return errors.New("email is required")
+	}
+
+	var password string
+	if !*sendLink {
+		var err error
+		password, err = readPassword()
+		if err != nil {
+			return err
+		}
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	client := domain.ClientInfo{IP: CLI_CLIENT, UserAgent: CLI_CLIENT}
+	if *sendLink {
+		err = a.AuthUsecase.ResetPassword(context.Background(), *email, client)
+		if err != nil {
+			return commandError(err)
+		}
+
+		fmt.Println("a reset link is queued for", *email, "if the account exists, it is sent by a running server")
+		return nil
+	}
+
+	err = a.AuthUsecase.SetPassword(context.Background(), *email, password, client)
+	if err != nil {
+		return commandError(err)
+	}
+
+	fmt.Println("password of", *email, "is changed and its sessions are revoked")
+	return nil
+}
+
+func runPurgeOrphanImages(args []string) error {
+	flags := flag.NewFlagSet("purge-orphan-images", flag.ExitOnError)
+	olderThan := flags.Duration("older-than", 24*time.Hour, "only remove images uploaded longer ago than this")
+	dryRun := flags.Bool("dry-run", false, "list the images without removing them")
+	flags.Parse(args)
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	imageURLs, err := a.ImageUsecase.PurgeOrphanImages(context.Background(), *olderThan, *dryRun)
+	for _, imageURL := range imageURLs {
+		if *dryRun {
+			fmt.Println("would remove", imageURL)
+		} else {
+			fmt.Println("removed", imageURL)
+		}
+	}
+	if err != nil {
+		return commandError(err)
+	}
+
+	fmt.Println(len(imageURLs), "orphan images")
+	return nil
+}
+
+func runRevokeSessions(args []string) error {
+	flags := flag.NewFlagSet("revoke-sessions", flag.ExitOnError)
+	email := flags.String("email", "", "email of the account (required)")
+	flags.Parse(args)
+
+	if *email == "" {
+		flags.Usage()
+		return errors.New("email is required")
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	err = a.AuthUsecase.RevokeSessions(context.Background(), *email)
+	if err != nil {
+		return commandError(err)
+	}
+
+	fmt.Println("revoked every session of", *email)
+	return nil
+}
+
+// readPassword asks for a password on the terminal without echoing it, or reads the first line
+// of stdin when it is not a terminal. Passwords are not taken as flags so they do not end up in
+// the shell history.
+func readPassword() (string, error) {
+	stdin := int(os.Stdin.Fd())
+	if terminal.IsTerminal(stdin) {
+		fmt.Fprint(os.Stderr, "password : ")
+		password, err := terminal.ReadPassword(stdin)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		return string(password), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("a password is expected on stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// commandError turns the friendly message and details of a usecase error into the error printed
+// by the command.
+func commandError(err error) error {
+	cerr, ok := err.(cerror.Error)
+	if !ok {
+		return err
+	}
+
+	messages := append([]string{cerr.FriendlyMessageWithTag()}, cerr.Details...)
+	return errors.New(strings.Join(messages, ", "))
+}
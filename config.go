@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/global"
+)
+
+const CONFIG_USAGE = "usage : config print [--redacted]"
+
+// runConfig handles `config print`, which prints the config the app would run with, after the
+// environment overrides, and then the problems validation finds in it.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New(CONFIG_USAGE)
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ExitOnError)
+	redacted := flags.Bool("redacted", false, "replace passwords and secrets with "+config.REDACTED)
+	flags.Parse(args[1:])
+	if flags.NArg() > 0 {
+		return errors.New(CONFIG_USAGE)
+	}
+
+	global.InitEnv()
+	configuration, fileName, err := config.Read()
+	if err != nil {
+		return err
+	}
+
+	if fileName == "" {
+		fileName = "none"
+	}
+	fmt.Fprintln(os.Stderr, "environment : "+global.Env)
+	fmt.Fprintln(os.Stderr, "config file : "+fileName)
+	for _, key := range configuration.DeprecatedKeys() {
+		fmt.Fprintln(os.Stderr, "deprecated : "+key+" is ignored")
+	}
+
+	if *redacted {
+		configuration = configuration.Redacted()
+	}
+
+	content, err := configuration.YAML()
+	if err != nil {
+		return err
+	}
+	fmt.Println(content)
+
+	return configuration.Validate()
+}
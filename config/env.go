@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ghodss/yaml"
+)
+
+// ENV_PREFIX starts the name of every environment variable that overrides the config.
+const ENV_PREFIX = "PERSONAL"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// applyEnv overrides the config with environment variables named after the path of the field in
+// upper snake case, for example PERSONAL_DB_PASSWORD, PERSONAL_SERVER_ADDR or
+// PERSONAL_OIDC_GOOGLE_CLIENT_SECRET for a provider in the config file. Lists of strings are
+// comma separated, other lists and maps are written as YAML or JSON, for example
+// PERSONAL_JWT_KEYS='[{"KID":"2021-01","PrivateKeyFile":"jwt-2021-01.pem"}]'.
+func applyEnv(config *Configuration) error {
+	return applyEnvToFields(reflect.ValueOf(config).Elem(), ENV_PREFIX)
+}
+
+func applyEnvToFields(value reflect.Value, prefix string) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			//unexported, not part of the config file either
+			continue
+		}
+		err := applyEnvToValue(value.Field(i), prefix+"_"+envName(field.Name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyEnvToValue(value reflect.Value, name string) error {
+	if value.Kind() == reflect.Struct && value.Type() != timeType {
+		return applyEnvToFields(value, name)
+	}
+
+	envValue, ok := os.LookupEnv(name)
+	if ok {
+		err := setFromEnv(value, envValue)
+		if err != nil {
+			return fmt.Errorf("invalid value of %s : %w", name, err)
+		}
+	}
+
+	//entries of a map can be overridden one by one, OIDC providers for example
+	if value.Kind() == reflect.Map && value.Type().Elem().Kind() == reflect.Struct {
+		for _, key := range value.MapKeys() {
+			entry := reflect.New(value.Type().Elem()).Elem()
+			entry.Set(value.MapIndex(key))
+
+			err := applyEnvToValue(entry, name+"_"+envName(key.String()))
+			if err != nil {
+				return err
+			}
+			value.SetMapIndex(key, entry)
+		}
+	}
+
+	return nil
+}
+
+func setFromEnv(value reflect.Value, envValue string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(envValue)
+		return nil
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(envValue)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(envValue, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(envValue, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+		return nil
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(envValue), "[") {
+			var items []string
+			for _, item := range strings.Split(envValue, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			value.Set(reflect.ValueOf(items))
+			return nil
+		}
+	}
+
+	if value.Type() == timeType && envValue == "" {
+		value.Set(reflect.Zero(timeType))
+		return nil
+	}
+
+	parsed := reflect.New(value.Type())
+	err := yaml.Unmarshal([]byte(envValue), parsed.Interface())
+	if err != nil {
+		return err
+	}
+	value.Set(parsed.Elem())
+	return nil
+}
+
+// envName turns a field name into upper snake case, FEHost becomes FE_HOST and
+// QueryTimeoutSeconds becomes QUERY_TIMEOUT_SECONDS. Map keys like google-workspace become
+// GOOGLE_WORKSPACE.
+func envName(name string) string {
+	runes := []rune(name)
+
+	var builder strings.Builder
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			builder.WriteRune('_')
+			continue
+		}
+
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				builder.WriteRune('_')
+			}
+		}
+		builder.WriteRune(unicode.ToUpper(r))
+	}
+
+	return builder.String()
+}
@@ -1,28 +1,249 @@
 package config
 
 import (
-	"log"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
 
+	"github.com/ghodss/yaml"
+	"github.com/joho/godotenv"
 	"github.com/pajri/personal-backend/global"
-	"github.com/tkanos/gonfig"
 )
 
-var Config Configuration
+const (
+	DEFAULT_SERVER_ADDR = ":5000"
 
-func InitConfig() {
-	config := Configuration{}
-	err := gonfig.GetConf("config/"+configFileName(), &config)
+	// CONFIG_FILE_ENV points at the config file to read instead of looking for one in config/.
+	CONFIG_FILE_ENV = "PERSONAL_CONFIG"
+
+	REDACTED = "REDACTED"
+)
+
+// DEPRECATED_CONFIG_KEYS were read by earlier versions. A config file that still has them is
+// accepted, the keys are ignored and reported by DeprecatedKeys.
+var DEPRECATED_CONFIG_KEYS = map[string][]string{
+	"EmailVerification": {"Subject"},
+	"ResetPassword":     {"Subject"},
+}
+
+// CONFIG_FILE_EXTENSIONS are tried in this order. JSON is valid YAML, so every file is read
+// the same way.
+var CONFIG_FILE_EXTENSIONS = []string{".yaml", ".yml", ".json"}
+
+// Load reads and validates the config of the current environment. It returns the file that was
+// read like Read.
+func Load() (Configuration, string, error) {
+	config, fileName, err := Read()
+	if err != nil {
+		return Configuration{}, "", err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return Configuration{}, "", err
+	}
+
+	return config, fileName, nil
+}
+
+// Read builds the config in layers : the defaults, then the config file, then environment
+// variables. Variables from .env (or <env>.env) are loaded first without replacing the ones
+// already set, so they count as environment variables. It returns the file that was read, which
+// is empty when there is none and the config comes from the environment only.
+func Read() (Configuration, string, error) {
+	err := godotenv.Load(global.EnvFileName())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Configuration{}, "", fmt.Errorf("unable to load %s : %w", global.EnvFileName(), err)
+	}
+
+	config := Configuration{
+		Server: ServerConfig{Addr: DEFAULT_SERVER_ADDR},
+	}
+
+	fileName, err := findConfigFile()
+	if err != nil {
+		return Configuration{}, "", err
+	}
+
+	if fileName != "" {
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return Configuration{}, "", fmt.Errorf("unable to read config : %w", err)
+		}
+
+		err = parseConfigFile(content, &config)
+		if err != nil {
+			return Configuration{}, "", fmt.Errorf("unable to parse %s : %w", fileName, err)
+		}
+	}
+
+	err = applyEnv(&config)
+	if err != nil {
+		return Configuration{}, "", err
+	}
+
+	//JWT_SECRET was read from .env before it became part of the config
+	if config.JWT.Secret == "" {
+		config.JWT.Secret = os.Getenv("JWT_SECRET")
+	}
+
+	return config, fileName, nil
+}
+
+// findConfigFile returns PERSONAL_CONFIG when it is set, otherwise the first of
+// config/config[.<env>].yaml, .yml and .json that exists.
+func findConfigFile() (string, error) {
+	fileName := os.Getenv(CONFIG_FILE_ENV)
+	if fileName != "" {
+		_, err := os.Stat(fileName)
+		if err != nil {
+			return "", fmt.Errorf("unable to read config %s from %s : %w", fileName, CONFIG_FILE_ENV, err)
+		}
+		return fileName, nil
+	}
+
+	for _, extension := range CONFIG_FILE_EXTENSIONS {
+		fileName = "config/" + configFileBaseName() + extension
+		_, err := os.Stat(fileName)
+		if err == nil {
+			return fileName, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("unable to read config %s : %w", fileName, err)
+		}
+	}
+
+	return "", nil
+}
+
+// parseConfigFile reads YAML or JSON and refuses keys the config does not have, so a misspelled
+// key is reported instead of silently leaving its value empty. Deprecated keys are dropped first.
+func parseConfigFile(content []byte, config *Configuration) error {
+	jsonContent, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return err
+	}
+
+	jsonContent, config.deprecatedKeys, err = dropDeprecatedKeys(jsonContent)
 	if err != nil {
-		log.Fatal("unable to read config : ", err)
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonContent))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(config)
+}
+
+// dropDeprecatedKeys removes DEPRECATED_CONFIG_KEYS from the file and returns the ones it found.
+// A section the config does not have anymore is removed too once it is empty.
+func dropDeprecatedKeys(jsonContent []byte) ([]byte, []string, error) {
+	var sections map[string]json.RawMessage
+	err := json.Unmarshal(jsonContent, &sections)
+	if err != nil || sections == nil {
+		//not an object, the decoder reports it
+		return jsonContent, nil, nil
+	}
+
+	var found []string
+	sectionNames := make([]string, 0, len(DEPRECATED_CONFIG_KEYS))
+	for sectionName := range DEPRECATED_CONFIG_KEYS {
+		sectionNames = append(sectionNames, sectionName)
+	}
+	sort.Strings(sectionNames)
+
+	for _, sectionName := range sectionNames {
+		var section map[string]json.RawMessage
+		err = json.Unmarshal(sections[sectionName], &section)
+		if err != nil || section == nil {
+			continue
+		}
+
+		for _, key := range DEPRECATED_CONFIG_KEYS[sectionName] {
+			if _, ok := section[key]; ok {
+				delete(section, key)
+				found = append(found, sectionName+"."+key)
+			}
+		}
+
+		_, isSection := reflect.TypeOf(Configuration{}).FieldByName(sectionName)
+		if len(section) == 0 && !isSection {
+			delete(sections, sectionName)
+			continue
+		}
+
+		sections[sectionName], err = json.Marshal(section)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(found) == 0 {
+		return jsonContent, nil, nil
 	}
 
-	Config = config
+	jsonContent, err = json.Marshal(sections)
+	return jsonContent, found, err
 }
 
-func configFileName() string {
+// DeprecatedKeys returns the deprecated keys the config file still has, they are ignored.
+func (c Configuration) DeprecatedKeys() []string {
+	return c.deprecatedKeys
+}
+
+func configFileBaseName() string {
 	if global.IsEnvDevelopment() {
-		return "config.json"
+		return "config"
+	}
+
+	return "config." + global.Env
+}
+
+// Redacted returns a copy of the config with every password and secret replaced, for printing.
+func (c Configuration) Redacted() Configuration {
+	redacted := c
+	redacted.DB.Password = redact(c.DB.Password)
+	redacted.SMTP.Password = redact(c.SMTP.Password)
+	redacted.Redis.Password = redact(c.Redis.Password)
+	redacted.JWT.Secret = redact(c.JWT.Secret)
+	redacted.TOTP.RecoveryCodeSecret = redact(c.TOTP.RecoveryCodeSecret)
+
+	if c.Tracing.Headers != nil {
+		redacted.Tracing.Headers = make(map[string]string, len(c.Tracing.Headers))
+		for name, value := range c.Tracing.Headers {
+			redacted.Tracing.Headers[name] = redact(value)
+		}
+	}
+
+	if c.OIDC != nil {
+		redacted.OIDC = make(map[string]OIDCProviderConfig, len(c.OIDC))
+		for name, providerConfig := range c.OIDC {
+			providerConfig.ClientSecret = redact(providerConfig.ClientSecret)
+			redacted.OIDC[name] = providerConfig
+		}
 	}
 
-	return "config." + global.Env + ".json"
+	return redacted
+}
+
+// YAML renders the config the way it can be written in a config file.
+func (c Configuration) YAML() (string, error) {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return REDACTED
 }
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// BASELINE_README_CONFIG is the sample config of the first README, files written from it must
+// keep loading.
+const BASELINE_README_CONFIG = `{
+    "DB":{
+        "Host":"localhost",
+        "Port":3306,
+        "Username":"root",
+        "Password":"root",
+        "DbName":"personal"
+    },
+    "SMTP":{
+        "From":"sample@mail.com",
+        "Host":"localhost",
+        "Port":1025,
+        "Username":"username",
+        "Password":"password"
+    },
+    "EmailVerification":{
+        "Subject":"Email Verification"
+    },
+    "ResetPassword":{
+        "Subject":"Reset Password"
+    },
+    "Redis":{
+        "Host":"localhost",
+        "Password":"",
+        "Port":6379
+    },
+    "Host":"http://mymoment.localdev.info",
+    "FEHost":"http://mymoment.localdev.info"
+}`
+
+func readConfigFile(t *testing.T, content string) (Configuration, error) {
+	fileName := filepath.Join(t.TempDir(), "config.json")
+	err := ioutil.WriteFile(fileName, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(CONFIG_FILE_ENV, fileName)
+
+	config, _, err := Read()
+	return config, err
+}
+
+func TestReadBaselineReadmeConfig(t *testing.T) {
+	config, err := readConfigFile(t, BASELINE_README_CONFIG)
+	if err != nil {
+		t.Fatalf("unable to read the baseline config : %v", err)
+	}
+
+	if config.DB.Host != "localhost" || config.DB.DbName != "personal" || config.SMTP.Port != 1025 || config.Redis.Port != 6379 {
+		t.Fatalf("baseline config not read : %+v", config)
+	}
+
+	wantDeprecated := []string{"EmailVerification.Subject", "ResetPassword.Subject"}
+	if !reflect.DeepEqual(config.DeprecatedKeys(), wantDeprecated) {
+		t.Fatalf("deprecated keys %v, want %v", config.DeprecatedKeys(), wantDeprecated)
+	}
+}
+
+func TestReadConfigWithUnknownKey(t *testing.T) {
+	_, err := readConfigFile(t, `{"DB":{"Hots":"localhost"}}`)
+	if err == nil || !strings.Contains(err.Error(), "Hots") {
+		t.Fatalf("error %v, want the misspelled key reported", err)
+	}
+}
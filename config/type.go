@@ -1,37 +1,152 @@
 package config
 
+import "time"
+
+// Configuration is read from config/config[.<env>].yaml, .yml or .json and every field can be
+// overridden with an environment variable, see Read.
 type Configuration struct {
+	Server            ServerConfig
+	Log               LogConfig
+	Tracing           TracingConfig
+	Metrics           MetricsConfig
 	DB                DBConfig
 	SMTP              SMTP
+	Mail              MailConfig
 	Host              string
 	FEHost            string
 	EmailVerification EmailVerificationConfig
-	ResetPassword     ResetPasswordConfig
+	EmailQueue        EmailQueueConfig
 	Redis             RedisConfig
+	TOTP              TOTPConfig
+	OIDC              map[string]OIDCProviderConfig
+	JWT               JWTConfig
+	PasswordHash      PasswordHashConfig
+	PasswordPolicy    PasswordPolicyConfig
+
+	deprecatedKeys []string
+}
+
+// PasswordPolicyConfig holds the rules for new passwords. MinStrengthScore goes from 0 to 4,
+// 0 uses the default of 3 and -1 turns the strength check off.
+type PasswordPolicyConfig struct {
+	MinLength            int
+	MaxLength            int
+	RequireUppercase     bool
+	RequireLowercase     bool
+	RequireDigit         bool
+	RequireSymbol        bool
+	MinStrengthScore     int
+	BreachedPasswordFile string
+}
+
+// PasswordHashConfig holds the argon2id cost parameters. Memory is in KiB.
+type PasswordHashConfig struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// JWTConfig holds the signing keys. Secret signs HS256 tokens when no key is active and
+// verifies tokens without a kid until a key is active, or until SecretExpireAt when that is later.
+type JWTConfig struct {
+	Secret         string
+	SecretExpireAt time.Time
+	Keys           []JWTKeyConfig
+}
+
+type JWTKeyConfig struct {
+	KID            string
+	PrivateKeyFile string
+	PublicKeyFile  string
+	ActivateAt     time.Time
+	ExpireAt       time.Time
+}
+
+// ServerConfig holds the http server. ReadTimeoutSeconds bounds reading a whole request including
+// uploads, WriteTimeoutSeconds writing the response and IdleTimeoutSeconds keep-alive connections.
+// On SIGINT or SIGTERM requests in flight get ShutdownTimeoutSeconds to finish. Zero uses the
+// defaults in the README.
+type ServerConfig struct {
+	Addr                   string
+	ReadTimeoutSeconds     int
+	WriteTimeoutSeconds    int
+	IdleTimeoutSeconds     int
+	MaxHeaderBytes         int
+	ShutdownTimeoutSeconds int
+}
+
+// LogConfig holds the logger. Level is "debug", "info" (the default), "warn" or "error". Format is
+// "json" or "text", empty uses text in the dev environment and json everywhere else.
+type LogConfig struct {
+	Level  string
+	Format string
+}
+
+// TracingConfig holds the OpenTelemetry exporter. Exporter is empty to turn tracing off, "otlp" to
+// send spans over OTLP/HTTP to Endpoint (host:port, localhost:4318 by default) or "stdout" to print
+// them to stderr. SampleRatio is the share of traces that are kept, from 0 to 1, 0 keeps all of them.
+// Headers are sent with every export, an API key of a tracing vendor for example.
+type TracingConfig struct {
+	Exporter    string
+	Endpoint    string
+	URLPath     string
+	Insecure    bool
+	Headers     map[string]string
+	ServiceName string
+	SampleRatio float64
+}
+
+// MetricsConfig holds the listener of /metrics, which is kept apart from the API so it can be
+// bound to an internal address. Addr is empty to not serve the metrics.
+type MetricsConfig struct {
+	Addr string
 }
 
+// DBConfig holds the MySQL connection. A query that takes longer than QueryTimeoutSeconds is
+// cancelled, 0 uses the default of 5 seconds. AutoMigrate applies pending migrations on start.
 type DBConfig struct {
-	Host     string
-	Port     int
-	Username string
-	Password string
-	DbName   string
+	Host                string
+	Port                int
+	Username            string
+	Password            string
+	DbName              string
+	QueryTimeoutSeconds int
+	AutoMigrate         bool
 }
 
+// SMTP holds the server the smtp transport sends through. Security is empty, "starttls" or "tls"
+// and Auth is "plain" (the default), "login", "cram-md5" or "none".
 type SMTP struct {
-	From     string
-	Host     string
-	Port     int
-	Username string
-	Password string
+	From               string
+	Host               string
+	Port               int
+	Username           string
+	Password           string
+	Security           string
+	Auth               string
+	InsecureSkipVerify bool
+}
+
+// MailConfig chooses how emails leave the app. Transport is "smtp" (the default), "file" to write
+// them into Directory, "log" or "noop".
+type MailConfig struct {
+	Transport string
+	Directory string
 }
 
 type EmailVerificationConfig struct {
-	Subject string
+	ResendCooldownSeconds int
 }
 
-type ResetPasswordConfig struct {
-	Subject string
+// EmailQueueConfig holds how the outbox worker sends emails. A failed email is tried again after
+// RetryBaseSeconds, doubling every attempt up to RetryMaxSeconds, until MaxAttempts is reached.
+type EmailQueueConfig struct {
+	PollIntervalSeconds int
+	BatchSize           int
+	MaxAttempts         int
+	RetryBaseSeconds    int
+	RetryMaxSeconds     int
+	SentRetentionHours  int
 }
 
 type RedisConfig struct {
@@ -39,3 +154,18 @@ type RedisConfig struct {
 	Port     int
 	Password string
 }
+
+// TOTPConfig holds the two-factor authentication settings. RecoveryCodeSecret keys the hash of
+// the recovery codes.
+type TOTPConfig struct {
+	Issuer             string
+	RecoveryCodeSecret string
+}
+
+type OIDCProviderConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ValidationError lists every problem found in the config, so all of them can be fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e ValidationError) Error() string {
+	return "invalid config :\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+type validator struct {
+	problems []string
+}
+
+// Validate checks that the values the app needs are set and that the others are in range.
+// Zero values that have a default are accepted.
+func (c Configuration) Validate() error {
+	v := new(validator)
+
+	v.address("Server.Addr", c.Server.Addr)
+	v.notNegative("Server.ReadTimeoutSeconds", c.Server.ReadTimeoutSeconds)
+	v.notNegative("Server.WriteTimeoutSeconds", c.Server.WriteTimeoutSeconds)
+	v.notNegative("Server.IdleTimeoutSeconds", c.Server.IdleTimeoutSeconds)
+	v.notNegative("Server.MaxHeaderBytes", c.Server.MaxHeaderBytes)
+	v.notNegative("Server.ShutdownTimeoutSeconds", c.Server.ShutdownTimeoutSeconds)
+	v.oneOf("Log.Level", c.Log.Level, "", "debug", "info", "warn", "error")
+	v.oneOf("Log.Format", c.Log.Format, "", "json", "text")
+	switch c.Tracing.Exporter {
+	case "otlp":
+		if c.Tracing.Endpoint != "" {
+			v.address("Tracing.Endpoint", c.Tracing.Endpoint)
+		}
+	case "", "stdout":
+	default:
+		v.oneOf("Tracing.Exporter", c.Tracing.Exporter, "otlp", "stdout")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.problem(fmt.Sprintf("Tracing.SampleRatio must be from 0 to 1, got %g", c.Tracing.SampleRatio))
+	}
+	if c.Metrics.Addr != "" {
+		v.address("Metrics.Addr", c.Metrics.Addr)
+	}
+	v.url("Host", c.Host)
+	v.url("FEHost", c.FEHost)
+
+	v.required("DB.Host", c.DB.Host)
+	v.port("DB.Port", c.DB.Port, false)
+	v.required("DB.Username", c.DB.Username)
+	v.required("DB.DbName", c.DB.DbName)
+	v.notNegative("DB.QueryTimeoutSeconds", c.DB.QueryTimeoutSeconds)
+
+	v.required("Redis.Host", c.Redis.Host)
+	v.port("Redis.Port", c.Redis.Port, true)
+
+	v.required("TOTP.RecoveryCodeSecret", c.TOTP.RecoveryCodeSecret)
+
+	switch c.Mail.Transport {
+	case "", "smtp":
+		v.required("SMTP.Host", c.SMTP.Host)
+		v.port("SMTP.Port", c.SMTP.Port, true)
+		v.email("SMTP.From", c.SMTP.From)
+		v.oneOf("SMTP.Security", c.SMTP.Security, "", "starttls", "tls")
+		v.oneOf("SMTP.Auth", c.SMTP.Auth, "", "plain", "login", "cram-md5", "none")
+	case "file":
+		v.required("Mail.Directory", c.Mail.Directory)
+	case "log", "noop":
+	default:
+		v.oneOf("Mail.Transport", c.Mail.Transport, "smtp", "file", "log", "noop")
+	}
+
+	v.notNegative("EmailVerification.ResendCooldownSeconds", c.EmailVerification.ResendCooldownSeconds)
+	v.notNegative("EmailQueue.PollIntervalSeconds", c.EmailQueue.PollIntervalSeconds)
+	v.notNegative("EmailQueue.BatchSize", c.EmailQueue.BatchSize)
+	v.notNegative("EmailQueue.MaxAttempts", c.EmailQueue.MaxAttempts)
+	v.notNegative("EmailQueue.RetryBaseSeconds", c.EmailQueue.RetryBaseSeconds)
+	v.notNegative("EmailQueue.RetryMaxSeconds", c.EmailQueue.RetryMaxSeconds)
+	v.notNegative("EmailQueue.SentRetentionHours", c.EmailQueue.SentRetentionHours)
+
+	providerNames := make([]string, 0, len(c.OIDC))
+	for name := range c.OIDC {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+	for _, name := range providerNames {
+		providerConfig := c.OIDC[name]
+		v.url("OIDC."+name+".Issuer", providerConfig.Issuer)
+		v.required("OIDC."+name+".ClientID", providerConfig.ClientID)
+		v.url("OIDC."+name+".RedirectURL", providerConfig.RedirectURL)
+	}
+
+	if c.JWT.Secret == "" && len(c.JWT.Keys) == 0 {
+		v.problem("JWT.Secret or JWT.Keys is required")
+	}
+	if c.JWT.Secret == "" && !c.JWT.SecretExpireAt.IsZero() {
+		v.problem("JWT.SecretExpireAt needs JWT.Secret")
+	}
+	kids := make(map[string]bool, len(c.JWT.Keys))
+	for i, keyConfig := range c.JWT.Keys {
+		field := fmt.Sprintf("JWT.Keys[%d]", i)
+		v.required(field+".KID", keyConfig.KID)
+		if keyConfig.KID != "" && kids[keyConfig.KID] {
+			v.problem(fmt.Sprintf("%s.KID %s is used by another key", field, keyConfig.KID))
+		}
+		kids[keyConfig.KID] = true
+
+		if keyConfig.PrivateKeyFile == "" && keyConfig.PublicKeyFile == "" {
+			v.problem(field + ".PrivateKeyFile or " + field + ".PublicKeyFile is required")
+		}
+		if !keyConfig.ExpireAt.IsZero() && !keyConfig.ExpireAt.After(keyConfig.ActivateAt) {
+			v.problem(field + ".ExpireAt must be after ActivateAt")
+		}
+	}
+
+	policy := c.PasswordPolicy
+	v.notNegative("PasswordPolicy.MinLength", policy.MinLength)
+	v.notNegative("PasswordPolicy.MaxLength", policy.MaxLength)
+	if policy.MinLength > 0 && policy.MaxLength > 0 && policy.MaxLength < policy.MinLength {
+		v.problem("PasswordPolicy.MaxLength must not be less than MinLength")
+	}
+	if policy.MinStrengthScore < -1 || policy.MinStrengthScore > 4 {
+		v.problem(fmt.Sprintf("PasswordPolicy.MinStrengthScore must be from -1 to 4, got %d", policy.MinStrengthScore))
+	}
+
+	if len(v.problems) > 0 {
+		return ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (v *validator) problem(problem string) {
+	v.problems = append(v.problems, problem)
+}
+
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.problem(field + " is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) notNegative(field string, value int) {
+	if value < 0 {
+		v.problem(fmt.Sprintf("%s must not be negative, got %d", field, value))
+	}
+}
+
+func (v *validator) port(field string, port int, required bool) {
+	if port == 0 && !required {
+		return
+	}
+
+	if port < 1 || port > 65535 {
+		v.problem(fmt.Sprintf("%s must be from 1 to 65535, got %d", field, port))
+	}
+}
+
+func (v *validator) url(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.problem(fmt.Sprintf("%s must be an http or https url, got %q", field, value))
+	}
+}
+
+func (v *validator) address(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+
+	_, _, err := net.SplitHostPort(value)
+	if err != nil {
+		v.problem(fmt.Sprintf("%s must be host:port or :port, got %q", field, value))
+	}
+}
+
+func (v *validator) email(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+
+	_, err := mail.ParseAddress(value)
+	if err != nil {
+		v.problem(fmt.Sprintf("%s must be an email address, got %q", field, value))
+	}
+}
+
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	var names []string
+	for _, a := range allowed {
+		if a != "" {
+			names = append(names, a)
+		}
+	}
+	v.problem(fmt.Sprintf("%s must be one of %s, got %q", field, strings.Join(names, ", "), value))
+}
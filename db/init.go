@@ -2,16 +2,20 @@ package db
 
 import (
 	"database/sql"
+	"net"
+	"strconv"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/pajri/personal-backend/config"
 )
 
-func InitDB() (*sql.DB, error) {
-	dbConfig := config.Config.DB
+func InitDB(dbConfig config.DBConfig) (*sql.DB, error) {
 	cfg := mysql.NewConfig()
 	cfg.User = dbConfig.Username
 	cfg.Addr = dbConfig.Host
+	if _, _, err := net.SplitHostPort(dbConfig.Host); err != nil && dbConfig.Port != 0 {
+		cfg.Addr = net.JoinHostPort(dbConfig.Host, strconv.Itoa(dbConfig.Port))
+	}
 	cfg.Net = "tcp"
 	cfg.Params = map[string]string{"parseTime": "true"}
 	cfg.DBName = dbConfig.DbName
@@ -0,0 +1,364 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MIGRATION_LOCK_NAME    = "schema_migrations"
+	MIGRATION_LOCK_TIMEOUT = 60 * time.Second
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migration is a pair of <version>_<name>.up.sql and <version>_<name>.down.sql files in
+// db/migrations. Checksum is the sha256 of the up file, it is stored when the migration is
+// applied so a file that is changed afterwards is noticed.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus tells whether a migration has been applied. A migration that is in the
+// database but not in this build has no Up and Down.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations and records them in the schema_migrations table.
+// Only one Migrator works on a database at a time, the others wait for its lock.
+type Migrator struct {
+	conn       *sql.DB
+	migrations []Migration
+}
+
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func NewMigrator(conn *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{conn: conn, migrations: migrations}, nil
+}
+
+// Up applies every migration that has not been applied yet, oldest first, and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err = m.run(ctx, conn, migration.Up)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed : %w", migration.Version, migration.Name, err)
+			}
+
+			_, err = conn.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				migration.Version, migration.Name, migration.Checksum, time.Now())
+			if err != nil {
+				return fmt.Errorf("unable to record migration %d_%s : %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			err = m.run(ctx, conn, migration.Down)
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed : %w", migration.Version, migration.Name, err)
+			}
+
+			_, err = conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+			if err != nil {
+				return fmt.Errorf("unable to remove migration %d_%s : %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists the migrations of this build and those found in the database, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = m.createTable(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if appliedMigration, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedMigration.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, appliedMigration := range applied {
+		appliedAt := appliedMigration.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{
+				Version:  appliedMigration.Version,
+				Name:     appliedMigration.Name,
+				Checksum: appliedMigration.Checksum,
+			},
+			AppliedAt: &appliedAt,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// withLock runs fn on a connection that holds the migration lock. MySQL locks belong to the
+// connection they were taken on, so everything the migrator does goes through conn.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", MIGRATION_LOCK_NAME, int(MIGRATION_LOCK_TIMEOUT.Seconds())).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("another instance is migrating the database, gave up after %s", MIGRATION_LOCK_TIMEOUT)
+	}
+	defer conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", MIGRATION_LOCK_NAME)
+
+	err = m.createTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// verify returns the applied migrations after checking that each of them is still the same
+// file in this build.
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, appliedMigration := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d_%s is applied but not part of this build", version, appliedMigration.Name)
+		}
+
+		if migration.Checksum != appliedMigration.Checksum {
+			return nil, fmt.Errorf("migration %d_%s has been changed after it was applied", version, migration.Name)
+		}
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) createTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint NOT NULL,
+		name varchar(255) NOT NULL,
+		checksum char(64) NOT NULL,
+		applied_at datetime NOT NULL,
+		PRIMARY KEY (version)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var migration appliedMigration
+		err = rows.Scan(&migration.Version, &migration.Name, &migration.Checksum, &migration.AppliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[migration.Version] = migration
+	}
+
+	return applied, rows.Err()
+}
+
+// run executes the statements of a migration one by one. MySQL commits every schema change
+// right away, so a migration that fails halfway has to be cleaned up by hand.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, statement := range splitStatements(script) {
+		_, err := conn.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration file %s does not end with .up.sql or .down.sql", fileName)
+		}
+
+		baseName := strings.TrimSuffix(fileName, "."+direction+".sql")
+		separator := strings.Index(baseName, "_")
+		if separator <= 0 {
+			return nil, fmt.Errorf("migration file %s is not named <version>_<name>", fileName)
+		}
+
+		version, err := strconv.ParseInt(baseName[:separator], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has an invalid version : %w", fileName, err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: baseName[separator+1:]}
+			byVersion[version] = migration
+		} else if migration.Name != baseName[separator+1:] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, baseName[separator+1:])
+		}
+
+		if direction == "up" {
+			sum := sha256.Sum256(content)
+			migration.Up = string(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements splits a script on the semicolons that end a line. Lines starting with --
+// are comments.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
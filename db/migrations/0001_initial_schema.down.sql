@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `image`;
+DROP TABLE IF EXISTS `post`;
+DROP TABLE IF EXISTS `profile`;
+DROP TABLE IF EXISTS `account`;
@@ -0,0 +1,46 @@
+-- The schema as it was kept in etc/db_schema before migrations. The tables are only created when
+-- they do not exist yet, so a database set up from those files is migrated from here on.
+
+CREATE TABLE IF NOT EXISTS `account` (
+  `account_id` varchar(255) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `password` text NOT NULL,
+  `salt` binary(32) DEFAULT NULL,
+  `email_token` text,
+  `is_verified` tinyint(4) NOT NULL,
+  `password_token` varchar(1000) DEFAULT '',
+  PRIMARY KEY (`account_id`),
+  UNIQUE KEY `email_UNIQUE` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- argon2id keeps the salt in the hash, the column is cleared when a password is hashed again.
+-- etc/db_schema had it NOT NULL.
+ALTER TABLE `account`
+  MODIFY `salt` binary(32) DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `profile` (
+  `profile_id` varchar(255) NOT NULL,
+  `full_name` text,
+  `account_id` varchar(255) DEFAULT NULL,
+  PRIMARY KEY (`profile_id`),
+  KEY `fk_profile_account_idx` (`account_id`),
+  CONSTRAINT `fk_profile_account` FOREIGN KEY (`account_id`) REFERENCES `account` (`account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `post` (
+  `post_id` varchar(255) NOT NULL,
+  `content` text,
+  `image_url` text,
+  `date` datetime DEFAULT NULL,
+  `last_updated` varchar(45) DEFAULT NULL,
+  `account_id` varchar(45) DEFAULT NULL,
+  PRIMARY KEY (`post_id`),
+  KEY `fk_account_account_id_idx` (`account_id`),
+  CONSTRAINT `fk_account_account_id` FOREIGN KEY (`account_id`) REFERENCES `account` (`account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `image` (
+  `image_id` varchar(255) NOT NULL,
+  `image_url` text NOT NULL,
+  PRIMARY KEY (`image_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
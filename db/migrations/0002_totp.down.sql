@@ -0,0 +1,4 @@
+DROP TABLE `recovery_code`;
+ALTER TABLE `account`
+  DROP COLUMN `totp_secret`,
+  DROP COLUMN `is_totp_enabled`;
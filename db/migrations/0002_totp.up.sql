@@ -0,0 +1,13 @@
+ALTER TABLE `account`
+  ADD COLUMN `totp_secret` varchar(255) DEFAULT '',
+  ADD COLUMN `is_totp_enabled` tinyint(4) NOT NULL DEFAULT '0';
+
+CREATE TABLE `recovery_code` (
+  `recovery_code_id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `is_used` tinyint(4) NOT NULL DEFAULT '0',
+  PRIMARY KEY (`recovery_code_id`),
+  KEY `fk_recovery_code_account_idx` (`account_id`),
+  CONSTRAINT `fk_recovery_code_account` FOREIGN KEY (`account_id`) REFERENCES `account` (`account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
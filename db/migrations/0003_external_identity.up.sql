@@ -0,0 +1,12 @@
+CREATE TABLE `external_identity` (
+  `external_identity_id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `provider` varchar(100) NOT NULL,
+  `subject` varchar(255) NOT NULL,
+  `email` varchar(255) DEFAULT '',
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`external_identity_id`),
+  UNIQUE KEY `provider_subject_UNIQUE` (`provider`,`subject`),
+  KEY `fk_external_identity_account_idx` (`account_id`),
+  CONSTRAINT `fk_external_identity_account` FOREIGN KEY (`account_id`) REFERENCES `account` (`account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,4 @@
+ALTER TABLE `account`
+  ADD COLUMN `email_token` text AFTER `salt`,
+  ADD COLUMN `password_token` varchar(1000) DEFAULT '' AFTER `is_verified`;
+DROP TABLE `account_token`;
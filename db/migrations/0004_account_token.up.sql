@@ -0,0 +1,18 @@
+-- Tokens are stored as hashes in account_token now. Links sent before this migration stop
+-- working, the user can ask for a new one.
+CREATE TABLE `account_token` (
+  `account_token_id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `purpose` varchar(50) NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`account_token_id`),
+  UNIQUE KEY `token_hash_UNIQUE` (`token_hash`),
+  KEY `fk_account_token_account_idx` (`account_id`),
+  CONSTRAINT `fk_account_token_account` FOREIGN KEY (`account_id`) REFERENCES `account` (`account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+ALTER TABLE `account`
+  DROP COLUMN `email_token`,
+  DROP COLUMN `password_token`;
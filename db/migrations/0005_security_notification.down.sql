@@ -0,0 +1,3 @@
+ALTER TABLE `profile`
+  DROP COLUMN `notify_security_events`;
+DROP TABLE `account_device`;
@@ -0,0 +1,15 @@
+CREATE TABLE `account_device` (
+  `account_device_id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `device_hash` char(64) NOT NULL,
+  `user_agent` varchar(512) NOT NULL DEFAULT '',
+  `last_ip` varchar(45) NOT NULL DEFAULT '',
+  `first_seen_at` datetime NOT NULL,
+  `last_seen_at` datetime NOT NULL,
+  PRIMARY KEY (`account_device_id`),
+  UNIQUE KEY `account_device_UNIQUE` (`account_id`,`device_hash`),
+  CONSTRAINT `fk_account_device_account` FOREIGN KEY (`account_id`) REFERENCES `account` (`account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+ALTER TABLE `profile`
+  ADD COLUMN `notify_security_events` tinyint(4) NOT NULL DEFAULT '1';
@@ -0,0 +1,2 @@
+ALTER TABLE `profile`
+  DROP COLUMN `locale`;
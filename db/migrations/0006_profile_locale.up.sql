@@ -0,0 +1,2 @@
+ALTER TABLE `profile`
+  ADD COLUMN `locale` varchar(10) NOT NULL DEFAULT 'en';
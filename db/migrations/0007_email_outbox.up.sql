@@ -0,0 +1,16 @@
+-- The body of a sent or dead email is cleared, it can hold a sign in link or a reset token.
+CREATE TABLE `email_outbox` (
+  `email_id` varchar(255) NOT NULL,
+  `recipients` text NOT NULL,
+  `subject` varchar(998) NOT NULL,
+  `text_body` mediumtext,
+  `html_body` mediumtext,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `attempts` int(11) NOT NULL DEFAULT '0',
+  `last_error` text,
+  `next_attempt_at` datetime NOT NULL,
+  `created_at` datetime NOT NULL,
+  `sent_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`email_id`),
+  KEY `email_outbox_due_idx` (`status`,`next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,241 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DEFAULT_QUERY_TIMEOUT is used when no query timeout is configured.
+const DEFAULT_QUERY_TIMEOUT = 5 * time.Second
+
+// IDB is the part of *sql.DB the repositories use. Queries run in the transaction that
+// Transactor.WithinTx put in the context, when there is one.
+type IDB interface {
+	// WithQueryTimeout returns a context that expires after the configured query timeout,
+	// or earlier when ctx does.
+	WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc)
+	BeginTx(ctx context.Context) (ITx, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// ITx is the part of *sql.Tx the repositories use.
+type ITx interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	Commit() error
+	Rollback() error
+}
+
+type txKey struct{}
+
+type database struct {
+	*sql.DB
+	queryTimeout time.Duration
+	tracer       trace.Tracer
+}
+
+// joinedTx is handed out by BeginTx inside WithinTx. Committing and rolling back is left to
+// WithinTx, a repository that fails returns its error and that rolls the whole transaction back.
+type joinedTx struct {
+	*sql.Tx
+}
+
+// tracedTx starts a span for every statement of a transaction from BeginTx.
+type tracedTx struct {
+	ITx
+	tracer trace.Tracer
+}
+
+// Transactor runs functions in a transaction that is carried by their context.
+type Transactor struct {
+	conn   *sql.DB
+	tracer trace.Tracer
+}
+
+// New wraps conn for the repositories. A queryTimeout of 0 uses DEFAULT_QUERY_TIMEOUT. Every
+// query gets a span from tracer.
+func New(conn *sql.DB, queryTimeout time.Duration, tracer trace.Tracer) IDB {
+	if queryTimeout <= 0 {
+		queryTimeout = DEFAULT_QUERY_TIMEOUT
+	}
+	return database{DB: conn, queryTimeout: queryTimeout, tracer: tracer}
+}
+
+func NewTransactor(conn *sql.DB, tracer trace.Tracer) *Transactor {
+	return &Transactor{conn: conn, tracer: tracer}
+}
+
+func (d database) WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d.queryTimeout)
+}
+
+func (d database) BeginTx(ctx context.Context) (ITx, error) {
+	if tx := txFromContext(ctx); tx != nil {
+		return tracedTx{ITx: joinedTx{Tx: tx}, tracer: d.tracer}, nil
+	}
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return tracedTx{ITx: tx, tracer: d.tracer}, nil
+}
+
+func (d database) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, d.tracer, query)
+
+	var (
+		result sql.Result
+		err    error
+	)
+	if tx := txFromContext(ctx); tx != nil {
+		result, err = tx.ExecContext(ctx, query, args...)
+	} else {
+		result, err = d.DB.ExecContext(ctx, query, args...)
+	}
+
+	tracing.End(span, err)
+	return result, err
+}
+
+func (d database) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, d.tracer, query)
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if tx := txFromContext(ctx); tx != nil {
+		rows, err = tx.QueryContext(ctx, query, args...)
+	} else {
+		rows, err = d.DB.QueryContext(ctx, query, args...)
+	}
+
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (d database) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, d.tracer, query)
+
+	var row *sql.Row
+	if tx := txFromContext(ctx); tx != nil {
+		row = tx.QueryRowContext(ctx, query, args...)
+	} else {
+		row = d.DB.QueryRowContext(ctx, query, args...)
+	}
+
+	//a missing row is an answer, not a failed query
+	err := row.Err()
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	tracing.End(span, err)
+	return row
+}
+
+func (t tracedTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := t.tracer.Start(ctx, "mysql PREPARE", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemMySQL, semconv.DBStatementKey.String(query)))
+	stmt, err := t.ITx.PrepareContext(ctx, query)
+	tracing.End(span, err)
+	return stmt, err
+}
+
+func (t tracedTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, t.tracer, query)
+	result, err := t.ITx.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t tracedTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, t.tracer, query)
+	rows, err := t.ITx.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (t tracedTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, t.tracer, query)
+	row := t.ITx.QueryRowContext(ctx, query, args...)
+
+	err := row.Err()
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	tracing.End(span, err)
+	return row
+}
+
+// startQuerySpan names the span after the statement, "mysql SELECT" for example. The query is
+// recorded with its placeholders, the values are left out.
+func startQuerySpan(ctx context.Context, tracer trace.Tracer, query string) (context.Context, trace.Span) {
+	operation := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+	return tracer.Start(ctx, "mysql "+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMySQL,
+			semconv.DBOperationKey.String(operation),
+			semconv.DBStatementKey.String(query),
+		))
+}
+
+func (t joinedTx) Commit() error {
+	return nil
+}
+
+func (t joinedTx) Rollback() error {
+	return nil
+}
+
+// WithinTx runs fn in one transaction, which is committed when fn returns nil and rolled back
+// otherwise. Repository methods called with the context fn gets take part in it. Inside another
+// WithinTx, fn simply joins the outer transaction.
+func (t Transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	//the queries of the transaction are grouped under one span
+	ctx, span := t.tracer.Start(ctx, "mysql TRANSACTION", trace.WithAttributes(semconv.DBSystemMySQL))
+	err := t.withinTx(ctx, fn)
+	tracing.End(span, err)
+	return err
+}
+
+func (t Transactor) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := t.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "WTX00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = fn(context.WithValue(ctx, txKey{}, tx))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "WTX01", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+func txFromContext(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
@@ -1,21 +1,24 @@
 package domain
 
+import "context"
+
 type Account struct {
 	AccountID     string `json:"-"`
 	Password      string `json:"-"`
 	Email         string `json:"email"`
 	Salt          []byte `json:"-"`
-	EmailToken    string `json:"-"`
 	IsVerified    bool   `json:"-"`
-	PasswordToken string
+	TOTPSecret    string `json:"-"`
+	IsTOTPEnabled bool   `json:"-"`
 }
 
 type IAccountRepository interface {
-	GetAccount(filter AccountFilter) (*Account, error)
-	InsertAccount(account Account) (*Account, error)
-	UpdateIsVerified(accountID string, isVerified bool) error
-	UpdateSaltAndPassword(account Account) error
-	UpdatePasswordToken(account Account) error
+	GetAccount(ctx context.Context, filter AccountFilter) (*Account, error)
+	InsertAccount(ctx context.Context, account Account) (*Account, error)
+	UpdateIsVerified(ctx context.Context, accountID string, isVerified bool) error
+	UpdateSaltAndPassword(ctx context.Context, account Account) error
+	UpdateTOTP(ctx context.Context, account Account) error
+	DeleteAccount(ctx context.Context, accountID string) error
 }
 
 type AccountFilter struct {
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// AccountDevice is a device an account has signed in from, identified by the hash of its user agent.
+type AccountDevice struct {
+	AccountDeviceID string
+	AccountID       string
+	DeviceHash      string
+	UserAgent       string
+	LastIP          string
+	FirstSeenAt     time.Time
+	LastSeenAt      time.Time
+}
+
+type IAccountDeviceRepository interface {
+	HasAccountDevices(ctx context.Context, accountID string) (bool, error)
+	TouchAccountDevice(ctx context.Context, device AccountDevice) (bool, error)
+	DeleteAccountDevices(ctx context.Context, accountID string) error
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ACCOUNT_TOKEN_PURPOSE_EMAIL_VERIFICATION = "email_verification"
+	ACCOUNT_TOKEN_PURPOSE_PASSWORD_RESET     = "password_reset"
+)
+
+// AccountToken is a single use token sent by email. Only the hash of the token is stored,
+// the token itself only exists in the link that is sent to the user.
+type AccountToken struct {
+	AccountTokenID string
+	AccountID      string
+	Purpose        string
+	TokenHash      string
+	ExpiresAt      time.Time
+}
+
+type IAccountTokenRepository interface {
+	ReplaceAccountToken(ctx context.Context, token AccountToken) error
+	GetAccountToken(ctx context.Context, purpose, tokenHash string) (*AccountToken, error)
+	ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (*AccountToken, error)
+	DeleteAccountTokens(ctx context.Context, accountID string) error
+}
@@ -1,16 +1,49 @@
 package domain
 
 import (
-	"github.com/dgrijalva/jwt-go"
+	"context"
+	"time"
+
 	"github.com/pajri/personal-backend/helper"
 )
 
 type IAuthUsecase interface {
-	Login(account Account) (*helper.JWTWrapper, error)
-	SignUp(account Account, profile Profile) (*Account, *Profile, error)
-	VerifyEmail(token string) error
-	ResetPassword(email string) error
-	ChangePassword(token, password string) error
-	RefreshToken(refreshToken string) (*helper.JWTWrapper, error)
-	SignOut(accessToken, refreshToken *jwt.Token) error
+	Login(ctx context.Context, account Account, client ClientInfo) (*helper.JWTWrapper, *LoginChallenge, error)
+	LoginTOTP(ctx context.Context, challengeToken, code string, client ClientInfo) (*helper.JWTWrapper, error)
+	SendMagicLink(ctx context.Context, email string) error
+	LoginMagicLink(ctx context.Context, token string, client ClientInfo) (*helper.JWTWrapper, *LoginChallenge, error)
+	OIDCAuthURL(ctx context.Context, provider string) (authURL, state string, err error)
+	LoginOIDC(ctx context.Context, provider, state, code string, client ClientInfo) (*helper.JWTWrapper, *LoginChallenge, error)
+	SignUp(ctx context.Context, account Account, profile Profile) (*Account, *Profile, error)
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, email string, client ClientInfo) error
+	ChangePassword(ctx context.Context, token, password string, client ClientInfo) error
+	RefreshToken(ctx context.Context, refreshToken string) (*helper.JWTWrapper, error)
+	SignOut(ctx context.Context, accessToken *helper.AccessTokenClaims, refreshToken *helper.RefreshTokenClaims) error
+	EnrollTOTP(ctx context.Context, accountID string) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, accountID, code string, client ClientInfo) ([]string, error)
+	DisableTOTP(ctx context.Context, accountID, code string, client ClientInfo) error
+	DeleteAccount(ctx context.Context, accountID, password, code string) error
+	RevokeSessions(ctx context.Context, email string) error
+	VerifyAccount(ctx context.Context, email string) error
+	SetPassword(ctx context.Context, email, password string, client ClientInfo) error
+}
+
+// ClientInfo describes where a request comes from, it is shown in security notifications.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// LoginChallenge is returned by Login instead of a token pair when the account has two-factor
+// authentication enabled. The challenge token is exchanged for a token pair by LoginTOTP.
+type LoginChallenge struct {
+	ChallengeToken string
+	ExpTime        time.Time
+}
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
 }
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/pajri/personal-backend/helper"
+)
+
+const (
+	EMAIL_STATUS_PENDING = "pending"
+	EMAIL_STATUS_SENT    = "sent"
+	EMAIL_STATUS_DEAD    = "dead"
+)
+
+// OutboxEmail is a rendered email waiting in the outbox until the worker delivers it. Emails that
+// can not be delivered after the last attempt, or are rejected for good, are kept as dead.
+type OutboxEmail struct {
+	EmailID       string
+	Recipients    []string
+	Subject       string
+	TextBody      string
+	HTMLBody      string
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
+
+type EmailStats struct {
+	Pending         int
+	Retrying        int
+	Sent            int
+	Dead            int
+	OldestPendingAt *time.Time
+}
+
+type IEmailRepository interface {
+	InsertEmail(ctx context.Context, email OutboxEmail) error
+	ClaimDueEmails(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxEmail, error)
+	MarkEmailSent(ctx context.Context, emailID string, sentAt time.Time) error
+	MarkEmailFailed(ctx context.Context, email OutboxEmail) error
+	DeleteFinishedEmails(ctx context.Context, before time.Time) error
+	GetEmailStats(ctx context.Context) (*EmailStats, error)
+}
+
+// IEmailUsecase queues emails instead of sending them right away, so it can be used wherever
+// a helper.IEMail is needed.
+type IEmailUsecase interface {
+	helper.IEMail
+	RunWorker(stop <-chan struct{})
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ExternalIdentity links an account to a user of an OpenID Connect provider.
+type ExternalIdentity struct {
+	ExternalIdentityID string
+	AccountID          string
+	Provider           string
+	Subject            string
+	Email              string
+	CreatedAt          time.Time
+}
+
+type IExternalIdentityRepository interface {
+	GetExternalIdentity(ctx context.Context, filter ExternalIdentityFilter) (*ExternalIdentity, error)
+	InsertExternalIdentity(ctx context.Context, identity ExternalIdentity) error
+	DeleteExternalIdentities(ctx context.Context, accountID string) error
+}
+
+type ExternalIdentityFilter struct {
+	AccountID string
+	Provider  string
+	Subject   string
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "context"
+
+const (
+	HEALTH_STATUS_OK   = "ok"
+	HEALTH_STATUS_FAIL = "fail"
+)
+
+// DependencyHealth is the result of checking one dependency. Error is only set when it failed.
+type DependencyHealth struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Readiness is ok when every dependency is ok.
+type Readiness struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+}
+
+// BuildInfo describes the running binary. Version, Commit and BuildTime are set with -ldflags
+// when it is built, see the Makefile.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+type IHealthUsecase interface {
+	Readiness(ctx context.Context) Readiness
+	BuildInfo() BuildInfo
+}
@@ -1,9 +1,9 @@
 package domain
 
 import (
+	"context"
 	"mime/multipart"
-
-	"github.com/gin-gonic/gin"
+	"time"
 )
 
 type Image struct {
@@ -12,12 +12,14 @@ type Image struct {
 }
 
 type IImageRepository interface {
-	SaveImage(image Image) error
-	DeleteImage(image Image, deleteFile bool) error
+	SaveImage(ctx context.Context, image Image) error
+	DeleteImage(ctx context.Context, image Image) error
+	DeleteImageFile(image Image) error
 }
 
 type IImageUsecase interface {
-	SaveImage(c *gin.Context, imageFile *multipart.FileHeader, email string) (string, error)
+	SaveImage(ctx context.Context, imageFile *multipart.FileHeader, email string) (string, error)
+	PurgeOrphanImages(ctx context.Context, olderThan time.Duration, dryRun bool) ([]string, error)
 }
 
 type ImageFilter struct {
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"time"
 )
 
@@ -15,16 +16,19 @@ type Post struct {
 }
 
 type IPostRepository interface {
-	InsertPost(post Post) (*Post, error)
-	DeletePost(postID, accountID string) error
-	PostList(filter PostFilter) ([]Post, error)
-	GetPost(filter PostFilter) (*Post, error)
+	InsertPost(ctx context.Context, post Post) (*Post, error)
+	DeletePost(ctx context.Context, postID, accountID string) error
+	DeletePosts(ctx context.Context, accountID string) error
+	PostList(ctx context.Context, filter PostFilter) ([]Post, error)
+	GetPost(ctx context.Context, filter PostFilter) (*Post, error)
+	GetPostImageURLs(ctx context.Context, accountID string) ([]string, error)
+	IsImageUsed(ctx context.Context, imageURL string) (bool, error)
 }
 
 type IPostUsecase interface {
-	InsertPost(post Post) (*Post, error)
-	DeletePost(postID, accountID string) error
-	PostListing(accountId string, limit uint64, date time.Time) ([]Post, error)
+	InsertPost(ctx context.Context, post Post) (*Post, error)
+	DeletePost(ctx context.Context, postID, accountID string) error
+	PostListing(ctx context.Context, accountId string, limit uint64, date time.Time) ([]Post, error)
 }
 
 type PostFilter struct {
@@ -1,21 +1,33 @@
 package domain
 
+import "context"
+
 type Profile struct {
 	ProfileID string  `json:"-"`
 	FullName  string  `json:"full_name"`
 	AccountID string  `json:"-"`
 	Account   Account `json:"-"`
+
+	//NotifySecurityEvents turns off the security emails that are not critical, like new device logins
+	NotifySecurityEvents bool `json:"notify_security_events"`
+
+	//Locale is the preferred language of the account, emails are sent in it when there are templates for it
+	Locale string `json:"locale"`
 }
 
 type IProfileRepository interface {
-	InsertProfile(profile Profile) error
-	GetProfile(filter ProfileFilter) (*Profile, error)
-	UpdateFullName(profile Profile) error
+	InsertProfile(ctx context.Context, profile Profile) error
+	GetProfile(ctx context.Context, filter ProfileFilter) (*Profile, error)
+	UpdateFullName(ctx context.Context, profile Profile) error
+	UpdateNotifySecurityEvents(ctx context.Context, profile Profile) error
+	UpdateLocale(ctx context.Context, profile Profile) error
+	DeleteProfile(ctx context.Context, accountID string) error
 }
 
 type IProfileUsecase interface {
-	GetProfile(profile Profile) (*Profile, error)
-	UpdateProfile(profile Profile) error
+	GetProfile(ctx context.Context, profile Profile) (*Profile, error)
+	UpdateProfile(ctx context.Context, profile Profile) error
+	UpdateNotificationPreference(ctx context.Context, profile Profile) error
 }
 
 type ProfileFilter struct {
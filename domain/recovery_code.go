@@ -0,0 +1,16 @@
+package domain
+
+import "context"
+
+type RecoveryCode struct {
+	RecoveryCodeID string
+	AccountID      string
+	CodeHash       string
+	IsUsed         bool
+}
+
+type IRecoveryCodeRepository interface {
+	ReplaceRecoveryCodes(ctx context.Context, accountID string, codes []RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, accountID, codeHash string) error
+	DeleteRecoveryCodes(ctx context.Context, accountID string) error
+}
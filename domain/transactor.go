@@ -0,0 +1,10 @@
+package domain
+
+import "context"
+
+// ITransactor runs fn in one database transaction that is carried by the context fn gets.
+// Repository methods called with that context take part in it, everything they wrote is
+// committed when fn returns nil and rolled back otherwise.
+type ITransactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,298 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/db"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/util"
+)
+
+const recipientSeparator = ","
+
+type MySqlEmailRepository struct {
+	Db db.IDB
+}
+
+func NewMySqlEmailRepository(database db.IDB) domain.IEmailRepository {
+	return &MySqlEmailRepository{
+		Db: database,
+	}
+}
+
+func (er MySqlEmailRepository) InsertEmail(ctx context.Context, email domain.OutboxEmail) error {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if email.EmailID == "" {
+		email.EmailID = util.GenerateUUID()
+	}
+
+	/*start create sql*/
+	query := sq.Insert("email_outbox").
+		Columns("email_id, recipients, subject, text_body, html_body, status, attempts, next_attempt_at, created_at").
+		Values(email.EmailID, strings.Join(email.Recipients, recipientSeparator), email.Subject, email.TextBody, email.HTMLBody,
+			domain.EMAIL_STATUS_PENDING, 0, email.NextAttemptAt, email.CreatedAt)
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "IEM00", err, global.FRIENDLY_MESSAGE)
+	}
+	/*end create sql*/
+
+	/*start insert data*/
+	tx, err := er.Db.BeginTx(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "IEM01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, sqlString)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "IEM02", err, global.FRIENDLY_MESSAGE)
+	}
+	defer stmt.Close()
+
+	_, err = tx.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "IEM03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "IEM04", err, global.FRIENDLY_MESSAGE)
+	}
+	/*end insert data*/
+
+	return nil
+}
+
+// ClaimDueEmails returns up to limit pending emails that are due and moves their next attempt
+// lease into the future, so other workers skip them while they are being sent. An email whose
+// worker stops before marking it is picked up again once the lease has passed.
+func (er MySqlEmailRepository) ClaimDueEmails(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]domain.OutboxEmail, error) {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("email_id, recipients, subject, text_body, html_body, status, attempts, next_attempt_at, created_at").
+		From("email_outbox").
+		Where(sq.Eq{"status": domain.EMAIL_STATUS_PENDING}).
+		Where(sq.LtOrEq{"next_attempt_at": now}).
+		OrderBy("next_attempt_at").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CDE00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	tx, err := er.Db.BeginTx(ctx)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CDE01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	rows, err := tx.QueryContext(ctx, sqlString, args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CDE02", err, global.FRIENDLY_MESSAGE)
+	}
+
+	var emails []domain.OutboxEmail
+	for rows.Next() {
+		var email domain.OutboxEmail
+		var recipients string
+		err = rows.Scan(&email.EmailID, &recipients, &email.Subject, &email.TextBody, &email.HTMLBody,
+			&email.Status, &email.Attempts, &email.NextAttemptAt, &email.CreatedAt)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, cerror.NewAndPrintWithTagContext(ctx, "CDE03", err, global.FRIENDLY_MESSAGE)
+		}
+
+		email.Recipients = strings.Split(recipients, recipientSeparator)
+		emails = append(emails, email)
+	}
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		tx.Rollback()
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CDE04", err, global.FRIENDLY_MESSAGE)
+	}
+
+	if len(emails) == 0 {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	ids := make([]string, len(emails))
+	for i, email := range emails {
+		ids[i] = email.EmailID
+	}
+
+	sqlString, args, err = sq.Update("email_outbox").
+		Set("next_attempt_at", now.Add(lease)).
+		Where(sq.Eq{"email_id": ids}).
+		ToSql()
+	if err != nil {
+		tx.Rollback()
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CDE05", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = tx.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CDE06", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "CDE07", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return emails, nil
+}
+
+// MarkEmailSent also clears the body, which is not needed anymore and can hold a token.
+func (er MySqlEmailRepository) MarkEmailSent(ctx context.Context, emailID string, sentAt time.Time) error {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Update("email_outbox").
+		Set("status", domain.EMAIL_STATUS_SENT).
+		Set("sent_at", sentAt).
+		Set("last_error", nil).
+		Set("text_body", nil).
+		Set("html_body", nil).
+		Where(sq.Eq{"email_id": emailID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "MES00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = er.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "MES01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
+
+// MarkEmailFailed stores the attempts, error, next attempt and status the worker decided on. The
+// body of a dead email is cleared like the one of a sent email.
+func (er MySqlEmailRepository) MarkEmailFailed(ctx context.Context, email domain.OutboxEmail) error {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Update("email_outbox").
+		Set("status", email.Status).
+		Set("attempts", email.Attempts).
+		Set("last_error", email.LastError).
+		Set("next_attempt_at", email.NextAttemptAt).
+		Where(sq.Eq{"email_id": email.EmailID})
+
+	if email.Status == domain.EMAIL_STATUS_DEAD {
+		query = query.Set("text_body", nil).Set("html_body", nil)
+	}
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "MEF00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = er.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "MEF01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
+
+// DeleteFinishedEmails removes the emails sent before before and the dead emails queued before it.
+func (er MySqlEmailRepository) DeleteFinishedEmails(ctx context.Context, before time.Time) error {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Delete("email_outbox").
+		Where(sq.Or{
+			sq.And{sq.Eq{"status": domain.EMAIL_STATUS_SENT}, sq.Lt{"sent_at": before}},
+			sq.And{sq.Eq{"status": domain.EMAIL_STATUS_DEAD}, sq.Lt{"created_at": before}},
+		})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DSE00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = er.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DSE01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
+
+func (er MySqlEmailRepository) GetEmailStats(ctx context.Context) (*domain.EmailStats, error) {
+	ctx, cancel := er.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("status, attempts > 0, COUNT(*), MIN(created_at)").
+		From("email_outbox").
+		GroupBy("status, attempts > 0")
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GES00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	rows, err := er.Db.QueryContext(ctx, sqlString, args...)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GES01", err, global.FRIENDLY_MESSAGE)
+	}
+	defer rows.Close()
+
+	stats := new(domain.EmailStats)
+	for rows.Next() {
+		var (
+			status    string
+			retried   bool
+			count     int
+			createdAt time.Time
+		)
+		err = rows.Scan(&status, &retried, &count, &createdAt)
+		if err != nil {
+			return nil, cerror.NewAndPrintWithTagContext(ctx, "GES02", err, global.FRIENDLY_MESSAGE)
+		}
+
+		switch status {
+		case domain.EMAIL_STATUS_PENDING:
+			stats.Pending += count
+			if retried {
+				stats.Retrying += count
+			}
+			if stats.OldestPendingAt == nil || createdAt.Before(*stats.OldestPendingAt) {
+				oldest := createdAt
+				stats.OldestPendingAt = &oldest
+			}
+		case domain.EMAIL_STATUS_SENT:
+			stats.Sent += count
+		case domain.EMAIL_STATUS_DEAD:
+			stats.Dead += count
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GES03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/adapter/logger"
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/helper"
+	"github.com/pajri/personal-backend/metrics"
+	"github.com/pajri/personal-backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DEFAULT_EMAIL_POLL_INTERVAL  = 5 * time.Second
+	DEFAULT_EMAIL_BATCH_SIZE     = 10
+	DEFAULT_EMAIL_MAX_ATTEMPTS   = 8
+	DEFAULT_EMAIL_RETRY_BASE     = 30 * time.Second
+	DEFAULT_EMAIL_RETRY_MAX      = time.Hour
+	DEFAULT_EMAIL_SENT_RETENTION = 7 * 24 * time.Hour
+	EMAIL_CLAIM_LEASE            = 5 * time.Minute
+	EMAIL_PURGE_INTERVAL         = time.Hour
+	MAX_EMAIL_LAST_ERROR_LENGTH  = 1000
+)
+
+type EmailUsecase struct {
+	emailRepo     domain.IEmailRepository
+	templates     *helper.EmailTemplates
+	mailSender    helper.IMailSender
+	pollInterval  time.Duration
+	batchSize     int
+	maxAttempts   int
+	retryBase     time.Duration
+	retryMax      time.Duration
+	sentRetention time.Duration
+	metrics       *metrics.Metrics
+	tracer        trace.Tracer
+}
+
+func NewEmailUsecase(emailRepository domain.IEmailRepository,
+	_templates *helper.EmailTemplates,
+	_mailSender helper.IMailSender,
+	queueConfig config.EmailQueueConfig,
+	_metrics *metrics.Metrics,
+	tracer trace.Tracer) domain.IEmailUsecase {
+	uc := &EmailUsecase{
+		emailRepo:     emailRepository,
+		templates:     _templates,
+		mailSender:    _mailSender,
+		pollInterval:  time.Duration(queueConfig.PollIntervalSeconds) * time.Second,
+		batchSize:     queueConfig.BatchSize,
+		maxAttempts:   queueConfig.MaxAttempts,
+		retryBase:     time.Duration(queueConfig.RetryBaseSeconds) * time.Second,
+		retryMax:      time.Duration(queueConfig.RetryMaxSeconds) * time.Second,
+		sentRetention: time.Duration(queueConfig.SentRetentionHours) * time.Hour,
+		metrics:       _metrics,
+		tracer:        tracer,
+	}
+
+	if uc.pollInterval <= 0 {
+		uc.pollInterval = DEFAULT_EMAIL_POLL_INTERVAL
+	}
+
+	if uc.batchSize <= 0 {
+		uc.batchSize = DEFAULT_EMAIL_BATCH_SIZE
+	}
+
+	if uc.maxAttempts <= 0 {
+		uc.maxAttempts = DEFAULT_EMAIL_MAX_ATTEMPTS
+	}
+
+	if uc.retryBase <= 0 {
+		uc.retryBase = DEFAULT_EMAIL_RETRY_BASE
+	}
+
+	if uc.retryMax <= 0 {
+		uc.retryMax = DEFAULT_EMAIL_RETRY_MAX
+	}
+
+	if uc.sentRetention <= 0 {
+		uc.sentRetention = DEFAULT_EMAIL_SENT_RETENTION
+	}
+
+	_metrics.RegisterEmailOutbox(uc.outboxStats)
+
+	return uc
+}
+
+// SendMail renders the template and queues the email, the worker sends it later. The worker sends
+// in its own trace, the span of SendMail only covers rendering and queueing.
+func (uc EmailUsecase) SendMail(ctx context.Context, to []string, locale, templateName string, data interface{}) error {
+	ctx, span := uc.tracer.Start(ctx, "EmailUsecase.SendMail", trace.WithAttributes(attribute.String("email.template", templateName)))
+	err := uc.sendMail(ctx, to, locale, templateName, data)
+	tracing.End(span, err)
+	return err
+}
+
+func (uc EmailUsecase) sendMail(ctx context.Context, to []string, locale, templateName string, data interface{}) error {
+	content, err := uc.templates.Render(locale, templateName, data)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "SME00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	now := time.Now()
+	email := domain.OutboxEmail{
+		Recipients:    to,
+		Subject:       content.Subject,
+		TextBody:      content.TextBody,
+		HTMLBody:      content.HTMLBody,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+
+	return uc.emailRepo.InsertEmail(ctx, email)
+}
+
+// RunWorker sends due emails every poll interval until stop is closed. It is safe to run a worker
+// in every instance of the app, an email is only claimed by one of them at a time.
+func (uc EmailUsecase) RunWorker(stop <-chan struct{}) {
+	//the worker is not tied to a request, its queries are only bounded by the query timeout
+	ctx := context.Background()
+
+	ticker := time.NewTicker(uc.pollInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		for uc.processDueEmails(ctx) == uc.batchSize {
+			//a full batch means more emails are probably waiting
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+
+		if time.Since(lastPurge) >= EMAIL_PURGE_INTERVAL {
+			err := uc.emailRepo.DeleteFinishedEmails(ctx, time.Now().Add(-uc.sentRetention))
+			if err == nil {
+				lastPurge = time.Now()
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// outboxStats counts the outbox for the metrics.
+func (uc EmailUsecase) outboxStats(ctx context.Context) (metrics.EmailOutboxStats, error) {
+	stats, err := uc.emailRepo.GetEmailStats(ctx)
+	if err != nil {
+		return metrics.EmailOutboxStats{}, err
+	}
+
+	return metrics.EmailOutboxStats{
+		Pending:         stats.Pending,
+		Retrying:        stats.Retrying,
+		Sent:            stats.Sent,
+		Dead:            stats.Dead,
+		OldestPendingAt: stats.OldestPendingAt,
+	}, nil
+}
+
+// processDueEmails sends one batch and returns how many emails were claimed.
+func (uc EmailUsecase) processDueEmails(ctx context.Context) int {
+	emails, err := uc.emailRepo.ClaimDueEmails(ctx, time.Now(), uc.batchSize, EMAIL_CLAIM_LEASE)
+	if err != nil {
+		return 0
+	}
+
+	for _, email := range emails {
+		uc.deliver(ctx, email)
+	}
+	return len(emails)
+}
+
+func (uc EmailUsecase) deliver(ctx context.Context, email domain.OutboxEmail) {
+	content := &helper.EmailContent{
+		Subject:  email.Subject,
+		TextBody: email.TextBody,
+		HTMLBody: email.HTMLBody,
+	}
+
+	//the worker is not part of a request, every send starts a trace of its own
+	_, span := uc.tracer.Start(ctx, "mail send", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("email.id", email.EmailID),
+		attribute.Int("email.attempt", email.Attempts+1),
+	))
+	err := uc.mailSender.Send(email.Recipients, content)
+	tracing.End(span, err)
+	if err == nil {
+		uc.metrics.EmailSent()
+		_ = uc.emailRepo.MarkEmailSent(ctx, email.EmailID, time.Now())
+		return
+	}
+
+	email.Attempts++
+	email.LastError = err.Error()
+	if len(email.LastError) > MAX_EMAIL_LAST_ERROR_LENGTH {
+		email.LastError = email.LastError[:MAX_EMAIL_LAST_ERROR_LENGTH]
+	}
+
+	if helper.IsPermanentMailError(err) || email.Attempts >= uc.maxAttempts {
+		email.Status = domain.EMAIL_STATUS_DEAD
+		logger.WithTag(ctx, "DLE00").Errorf("email %s to %v is dead after %d attempts : %s", email.EmailID, email.Recipients, email.Attempts, email.LastError)
+	} else {
+		email.Status = domain.EMAIL_STATUS_PENDING
+		email.NextAttemptAt = time.Now().Add(uc.retryDelay(email.Attempts))
+	}
+
+	uc.metrics.EmailFailed(email.Status == domain.EMAIL_STATUS_DEAD)
+	_ = uc.emailRepo.MarkEmailFailed(ctx, email)
+}
+
+// retryDelay doubles the delay with every attempt and adds up to 10% jitter, so emails that
+// failed together are not all tried again at the same moment.
+func (uc EmailUsecase) retryDelay(attempts int) time.Duration {
+	delay := uc.retryMax
+	if attempts-1 < 32 {
+		if backoff := uc.retryBase << uint(attempts-1); backoff > 0 && backoff < uc.retryMax {
+			delay = backoff
+		}
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/10+1))
+}
@@ -5,6 +5,7 @@ const (
 	ERR_DIFFERENT_FORMATTER         = "%s must be the same with %s"
 	ERR_IMAGE_NOT_ALLOWED           = "image type %s is not allowed"
 	ERR_MIN_CHAR                    = "minimum character for %s is %s"
+	ERR_MAX_CHAR                    = "maximum character for %s is %s"
 	ERR_INVALID_FORMAT_REGEX        = "invalid format for %s, the text should match regex %s"
-	ERR_MAX_IMAGE_SIZE_EXCEED_LIMIT = "image size exceed limit %s MB. actual size %s. email %s"
+	ERR_MAX_IMAGE_SIZE_EXCEED_LIMIT = "image size exceed limit %d MB. actual size %d. email %s"
 )
@@ -15,4 +15,25 @@ const (
 	FRIENDLY_INVALID_FORMAT          = "invalid format for %s"
 	FRIENDLY_INVALID_PARAM           = "Invalid param"
 	FRIENDLY_IMAGE_SIZE_EXCEED_LIMIT = "Max image size is %d MB"
+	FRIENDLY_INVALID_OTP             = "Invalid verification code"
+	FRIENDLY_TOTP_ALREADY_ENABLED    = "Two-factor authentication is already enabled"
+	FRIENDLY_TOTP_NOT_ENABLED        = "Two-factor authentication is not enabled"
+	FRIENDLY_TOTP_NOT_ENROLLED       = "Two-factor authentication setup has not been started"
+	FRIENDLY_LOGIN_CHALLENGE_EXPIRED = "Login session is expired, please login again"
+	FRIENDLY_OIDC_FAILED             = "Unable to sign in with the external provider"
+	FRIENDLY_OIDC_UNKNOWN_PROVIDER   = "Sign in provider is not supported"
+	FRIENDLY_OIDC_EMAIL_UNVERIFIED   = "The external provider did not return a verified email"
+	FRIENDLY_RESEND_COOLDOWN         = "Please wait a moment before requesting another email"
+	FRIENDLY_PASSWORD_POLICY         = "Password does not meet the requirements"
+	FRIENDLY_PASSWORD_TOO_SHORT      = "Password must be at least %d characters"
+	FRIENDLY_PASSWORD_TOO_LONG       = "Password must be at most %d characters"
+	FRIENDLY_PASSWORD_NEED_UPPERCASE = "Password must contain an uppercase letter"
+	FRIENDLY_PASSWORD_NEED_LOWERCASE = "Password must contain a lowercase letter"
+	FRIENDLY_PASSWORD_NEED_DIGIT     = "Password must contain a digit"
+	FRIENDLY_PASSWORD_NEED_SYMBOL    = "Password must contain a symbol"
+	FRIENDLY_PASSWORD_PERSONAL_INFO  = "Password must not contain your email or name"
+	FRIENDLY_PASSWORD_TOO_WEAK       = "Password is too easy to guess"
+	FRIENDLY_PASSWORD_BREACHED       = "Password has appeared in a data breach, please choose another one"
+	FRIENDLY_POST_NOT_FOUND          = "Post is not found"
+	FRIENDLY_INVALID_PASSWORD        = "Password is incorrect"
 )
@@ -1,4 +1,17 @@
 package global
 
-const VERIFY_EMAIL_TEMPLATE = `Please click this <a href="%s">link</a> to verify email.`
-const RESET_PASSWORD_TEMPLATE = `Please click this <a href="%s">link</a> to change your password.`
+// DEFAULT_LOCALE is used for accounts without a preferred language and for locales
+// that have no email templates.
+const DEFAULT_LOCALE = "en"
+
+// names of the email templates in helper/templates/email/<locale>
+const (
+	EMAIL_TEMPLATE_VERIFY_EMAIL        = "verify_email"
+	EMAIL_TEMPLATE_RESET_PASSWORD      = "reset_password"
+	EMAIL_TEMPLATE_MAGIC_LINK          = "magic_link"
+	EMAIL_TEMPLATE_NEW_DEVICE_LOGIN    = "new_device_login"
+	EMAIL_TEMPLATE_PASSWORD_CHANGED    = "password_changed"
+	EMAIL_TEMPLATE_PASSWORD_RESET      = "password_reset_requested"
+	EMAIL_TEMPLATE_TWO_FACTOR_ENABLED  = "two_factor_enabled"
+	EMAIL_TEMPLATE_TWO_FACTOR_DISABLED = "two_factor_disabled"
+)
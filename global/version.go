@@ -0,0 +1,9 @@
+package global
+
+// Version, Commit and BuildTime are set when the binary is built, for example
+// go build -ldflags "-X github.com/pajri/personal-backend/global.Version=v1.2.0"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
@@ -1,11 +1,11 @@
 module github.com/pajri/personal-backend
 
-go 1.15
+go 1.16
 
 require (
 	github.com/Masterminds/squirrel v1.4.0
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
-	github.com/ghodss/yaml v1.0.0 // indirect
+	github.com/ghodss/yaml v1.0.0
 	github.com/gin-contrib/cors v1.3.1
 	github.com/gin-gonic/gin v1.6.3
 	github.com/go-playground/validator v9.31.0+incompatible
@@ -16,7 +16,13 @@ require (
 	github.com/joho/godotenv v1.3.0
 	github.com/kennygrant/sanitize v1.2.4
 	github.com/microcosm-cc/bluemonday v1.0.4
+	github.com/prometheus/client_golang v1.9.0
+	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/stew v0.0.0-20130812190256-80ef0842b48b
-	github.com/tkanos/gonfig v0.0.0-20181112185242-896f3d81fadf
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 )
@@ -0,0 +1,50 @@
+package delivery
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pajri/personal-backend/domain"
+)
+
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
+
+type HealthHandler struct {
+	useCase domain.IHealthUsecase
+}
+
+func NewHealthHandler(router *gin.Engine,
+	healthUsecase domain.IHealthUsecase) {
+	handler := &HealthHandler{
+		useCase: healthUsecase,
+	}
+
+	router.GET("/healthz", handler.Liveness)
+	router.GET("/readyz", handler.Readiness)
+	router.GET("/version", handler.Version)
+}
+
+// Liveness only tells that the process answers, a dependency that is down must not get the app
+// restarted.
+func (hh HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, LivenessResponse{Status: domain.HEALTH_STATUS_OK})
+}
+
+// Readiness answers 503 while a dependency is down, so the load balancer stops sending traffic.
+func (hh HealthHandler) Readiness(c *gin.Context) {
+	readiness := hh.useCase.Readiness(c.Request.Context())
+
+	httpStatus := http.StatusOK
+	if readiness.Status != domain.HEALTH_STATUS_OK {
+		httpStatus = http.StatusServiceUnavailable
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(httpStatus, readiness)
+}
+
+func (hh HealthHandler) Version(c *gin.Context) {
+	c.JSON(http.StatusOK, hh.useCase.BuildInfo())
+}
@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/helper"
+)
+
+// HEALTH_CHECK_TIMEOUT bounds every dependency check, so a hanging dependency makes the app
+// unready instead of hanging the load balancer's probe.
+const HEALTH_CHECK_TIMEOUT = 2 * time.Second
+
+type HealthUsecase struct {
+	dbConn      *sql.DB
+	redisHelper helper.IRedis
+	uploadDir   string
+}
+
+func NewHealthUsecase(_dbConn *sql.DB, _redisHelper helper.IRedis, _uploadDir string) domain.IHealthUsecase {
+	return &HealthUsecase{
+		dbConn:      _dbConn,
+		redisHelper: _redisHelper,
+		uploadDir:   _uploadDir,
+	}
+}
+
+// Readiness checks MySQL, Redis and the upload directory at the same time.
+func (uc HealthUsecase) Readiness(ctx context.Context) domain.Readiness {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"mysql", uc.dbConn.PingContext},
+		{"redis", uc.pingRedis},
+		{"upload_dir", uc.checkUploadDir},
+	}
+
+	results := make([]domain.DependencyHealth, len(checks))
+	done := make(chan struct{})
+	for i, c := range checks {
+		go func(i int, name string, check func(ctx context.Context) error) {
+			results[i] = runCheck(ctx, name, check)
+			done <- struct{}{}
+		}(i, c.name, c.check)
+	}
+	for range checks {
+		<-done
+	}
+
+	readiness := domain.Readiness{Status: domain.HEALTH_STATUS_OK, Dependencies: results}
+	for _, result := range results {
+		if result.Status != domain.HEALTH_STATUS_OK {
+			readiness.Status = domain.HEALTH_STATUS_FAIL
+		}
+	}
+	return readiness
+}
+
+func (uc HealthUsecase) BuildInfo() domain.BuildInfo {
+	return domain.BuildInfo{
+		Version:   global.Version,
+		Commit:    global.Commit,
+		BuildTime: global.BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// runCheck runs check with HEALTH_CHECK_TIMEOUT and measures how long it took. A check that does
+// not return in time is reported as failed and left to finish in the background.
+func runCheck(ctx context.Context, name string, check func(ctx context.Context) error) domain.DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+
+	start := time.Now()
+	result := make(chan error, 1)
+	go func() {
+		result <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	health := domain.DependencyHealth{
+		Name:      name,
+		Status:    domain.HEALTH_STATUS_OK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		health.Status = domain.HEALTH_STATUS_FAIL
+		health.Error = err.Error()
+	}
+	return health
+}
+
+// pingRedis ignores ctx because the redis connection does not take one, runCheck enforces the
+// timeout.
+func (uc HealthUsecase) pingRedis(ctx context.Context) error {
+	return uc.redisHelper.Ping(ctx)
+}
+
+// checkUploadDir creates and removes a file, uploads fail when the directory is missing, full or
+// read only.
+func (uc HealthUsecase) checkUploadDir(ctx context.Context) error {
+	file, err := os.CreateTemp(uc.uploadDir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+
+	name := file.Name()
+	err = file.Close()
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+	return os.Remove(name)
+}
@@ -1,38 +1,45 @@
 package helper
 
 import (
-	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"time"
 
-	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/adapter/logger"
 )
 
-type CookieHelper struct{}
+// CookieHelper sets cookies for the domain of the backend host.
+type CookieHelper struct {
+	domain string
+}
 
-func (ch CookieHelper) SetHttpOnlyCookie(name, value string, expire time.Time) *http.Cookie {
-	var host string
+func NewCookieHelper(host string) CookieHelper {
+	var domain string
 
 	//extract host
-	u, err := url.Parse(config.Config.Host)
+	u, err := url.Parse(host)
 	if err != nil {
-		log.Println("[SHO00] url parse error : " + err.Error())
+		logger.Entry().WithField(logger.TAG_FIELD, "NCH00").Error("url parse error : " + err.Error())
+		return CookieHelper{}
 	}
 	hostSplit, _, _ := net.SplitHostPort(u.Host)
 	if hostSplit != "" {
-		host = hostSplit
+		domain = hostSplit
 	} else {
-		host = u.Host
+		domain = u.Host
 	}
 
+	return CookieHelper{domain: domain}
+}
+
+func (ch CookieHelper) SetHttpOnlyCookie(name, value string, expire time.Time) *http.Cookie {
 	//set cookie
 	cookie := &http.Cookie{}
 	cookie.Name = name
 	cookie.Value = value
 	cookie.HttpOnly = true
-	cookie.Domain = host
+	cookie.Domain = ch.domain
 	cookie.Path = "/"
 	cookie.Expires = expire
 
@@ -40,29 +47,34 @@ func (ch CookieHelper) SetHttpOnlyCookie(name, value string, expire time.Time) *
 }
 
 func (ch CookieHelper) RemoveHttpOnlyCookie(name string) *http.Cookie {
-	var host string
-
-	//extract host
-	u, err := url.Parse(config.Config.Host)
-	if err != nil {
-		log.Println("[RHO00] url parse error : " + err.Error())
-	}
-	hostSplit, _, _ := net.SplitHostPort(u.Host)
-	if hostSplit != "" {
-		host = hostSplit
-	} else {
-		host = u.Host
-	}
-
 	//set cookie
 	cookie := &http.Cookie{}
 	cookie.Name = name
 	cookie.Value = ""
 	cookie.HttpOnly = true
-	cookie.Domain = host
+	cookie.Domain = ch.domain
 	cookie.Path = "/"
 	cookie.Expires = time.Time{}
 	cookie.MaxAge = -1
 
 	return cookie
 }
+
+// SetLaxHttpOnlyCookie sets a short-lived cookie that is only sent to path and survives the
+// top-level redirect back from a third party.
+func (ch CookieHelper) SetLaxHttpOnlyCookie(name, value, path string, maxAge time.Duration) *http.Cookie {
+	cookie := ch.SetHttpOnlyCookie(name, value, time.Now().Add(maxAge))
+	cookie.Path = path
+	cookie.MaxAge = int(maxAge.Seconds())
+	cookie.SameSite = http.SameSiteLaxMode
+
+	return cookie
+}
+
+func (ch CookieHelper) RemoveLaxHttpOnlyCookie(name, path string) *http.Cookie {
+	cookie := ch.RemoveHttpOnlyCookie(name)
+	cookie.Path = path
+	cookie.SameSite = http.SameSiteLaxMode
+
+	return cookie
+}
@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"fmt"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	TOKEN_TYPE_ACCESS  = "access"
+	TOKEN_TYPE_REFRESH = "refresh"
+
+	AUDIENCE_ACCESS  = "mymoment:api"
+	AUDIENCE_REFRESH = "mymoment:refresh"
+)
+
+var tokenAudience = map[string]string{
+	TOKEN_TYPE_ACCESS:  AUDIENCE_ACCESS,
+	TOKEN_TYPE_REFRESH: AUDIENCE_REFRESH,
+}
+
+// TokenClaims are the claims shared by every token we issue. Type and Audience tell the
+// purpose of the token, so a token issued for one purpose is rejected everywhere else.
+type TokenClaims struct {
+	jwt.StandardClaims
+	Type string `json:"typ"`
+}
+
+// issuedClaims are claims whose issuer can be checked, every claims type embeds TokenClaims.
+type issuedClaims interface {
+	jwt.Claims
+	VerifyIssuer(cmp string, req bool) bool
+}
+
+type AccessTokenClaims struct {
+	TokenClaims
+	Authorized bool   `json:"authorized"`
+	AccountID  string `json:"account_id"`
+	AccessUUID string `json:"access_uuid"`
+	Email      string `json:"email"`
+	FullName   string `json:"full_name"`
+}
+
+type RefreshTokenClaims struct {
+	TokenClaims
+	AccountID   string `json:"account_id"`
+	RefreshUUID string `json:"refresh_uuid"`
+}
+
+func (c AccessTokenClaims) Valid() error {
+	return c.validate(TOKEN_TYPE_ACCESS)
+}
+
+func (c RefreshTokenClaims) Valid() error {
+	return c.validate(TOKEN_TYPE_REFRESH)
+}
+
+func (c TokenClaims) validate(expectedType string) error {
+	err := c.StandardClaims.Valid()
+	if err != nil {
+		return err
+	}
+
+	if c.Type != expectedType {
+		return fmt.Errorf("token type %q is used where %q is expected", c.Type, expectedType)
+	}
+
+	if !c.VerifyAudience(tokenAudience[expectedType], true) {
+		return fmt.Errorf("token audience %q is not %q", c.Audience, tokenAudience[expectedType])
+	}
+
+	return nil
+}
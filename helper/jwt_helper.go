@@ -1,11 +1,13 @@
 package helper
 
 import (
-	"os"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/config"
 	"github.com/pajri/personal-backend/global"
 )
 
@@ -15,10 +17,37 @@ type JWTWrapper struct {
 	RefreshTokenExpTime time.Time `json:"-"`
 }
 
+// JWTHelper signs and verifies our tokens. Issuer is written to the iss claim of new tokens.
 type JWTHelper struct {
+	keys           *JWTKeySet
+	secret         string
+	secretExpireAt time.Time
+	issuer         string
 }
 
-func (j JWTHelper) CreateTokenPair(accessTokenParam, refreshTokenParam jwt.MapClaims) (*JWTWrapper, error) {
+func NewJWTHelper(jwtConfig config.JWTConfig, issuer string) (JWTHelper, error) {
+	keySet, err := NewJWTKeySet(jwtConfig.Keys)
+	if err != nil {
+		return JWTHelper{}, fmt.Errorf("unable to load jwt keys : %w", err)
+	}
+
+	return JWTHelper{keys: keySet, secret: jwtConfig.Secret, secretExpireAt: jwtConfig.SecretExpireAt, issuer: issuer}, nil
+}
+
+// NewTokenClaims returns the claims shared by every token of tokenType that expires at exp.
+func (j JWTHelper) NewTokenClaims(tokenType string, exp time.Time) TokenClaims {
+	return TokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  tokenAudience[tokenType],
+			ExpiresAt: exp.Unix(),
+			IssuedAt:  time.Now().Unix(),
+			Issuer:    j.issuer,
+		},
+		Type: tokenType,
+	}
+}
+
+func (j JWTHelper) CreateTokenPair(accessTokenParam AccessTokenClaims, refreshTokenParam RefreshTokenClaims) (*JWTWrapper, error) {
 	var (
 		token *JWTWrapper
 		err   error
@@ -38,9 +67,28 @@ func (j JWTHelper) CreateTokenPair(accessTokenParam, refreshTokenParam jwt.MapCl
 	return token, nil
 }
 
-func (j JWTHelper) CreateToken(claims jwt.MapClaims) (string, error) {
+// CreateToken signs with the active asymmetric key and puts its id in the kid header.
+// Without configured keys it falls back to HS256 with JWT.Secret.
+func (j JWTHelper) CreateToken(claims jwt.Claims) (string, error) {
+	signingKey := j.keys.SigningKey(time.Now())
+	if signingKey != nil {
+		jwtWithClaims := jwt.NewWithClaims(signingKey.Method, claims)
+		jwtWithClaims.Header["kid"] = signingKey.KID
+
+		token, err := jwtWithClaims.SignedString(signingKey.PrivateKey)
+		if err != nil {
+			return "", cerror.NewAndPrintWithTag("CTH01", err, global.FRIENDLY_MESSAGE)
+		}
+		return token, nil
+	}
+
+	secret := j.secret
+	if secret == "" {
+		return "", cerror.NewAndPrintWithTag("CTH02", errors.New("no active jwt key and JWT.Secret is empty"), global.FRIENDLY_MESSAGE)
+	}
+
 	jwtWithClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := jwtWithClaims.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	token, err := jwtWithClaims.SignedString([]byte(secret))
 	if err != nil {
 		return "", cerror.NewAndPrintWithTag("CTH00", err, global.FRIENDLY_MESSAGE)
 	}
@@ -48,13 +96,31 @@ func (j JWTHelper) CreateToken(claims jwt.MapClaims) (string, error) {
 	return token, nil
 }
 
-func (j JWTHelper) ParseToken(tokenString string) (*jwt.Token, error) {
-	claims := jwt.MapClaims{}
+func (j JWTHelper) ParseAccessToken(tokenString string) (*AccessTokenClaims, error) {
+	claims := new(AccessTokenClaims)
+	err := j.parseToken(tokenString, claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims,
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+func (j JWTHelper) ParseRefreshToken(tokenString string) (*RefreshTokenClaims, error) {
+	claims := new(RefreshTokenClaims)
+	err := j.parseToken(tokenString, claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parseToken verifies the signature and then the claims, whose Valid method rejects
+// tokens issued for another purpose. Tokens issued by another host are rejected as well.
+func (j JWTHelper) parseToken(tokenString string, claims issuedClaims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, j.verificationKey)
+	if err == nil && !claims.VerifyIssuer(j.issuer, true) {
+		err = fmt.Errorf("token issuer is not %s", j.issuer)
+	}
 
 	if err != nil {
 		friendlyMessage := global.FRIENDLY_INVALID_TOKEN
@@ -68,8 +134,44 @@ func (j JWTHelper) ParseToken(tokenString string) (*jwt.Token, error) {
 
 		cerr := cerror.NewAndPrintWithTag("PJW00", err, friendlyMessage)
 		cerr.Type = errorType
-		return token, cerr
+		return cerr
 	}
 
-	return token, nil
+	return nil
+}
+
+// JWKS returns the public keys other services need to verify our tokens.
+func (j JWTHelper) JWKS() JWKS {
+	return j.keys.JWKS(time.Now())
+}
+
+// verificationKey picks the key by kid and checks that the token algorithm matches the key,
+// so a token cannot pick a weaker algorithm than the key was issued for. Tokens without kid
+// are HS256 tokens signed with JWT.Secret, they are only accepted while no asymmetric key is
+// active or until JWT.SecretExpireAt.
+func (j JWTHelper) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid != "" {
+		key := j.keys.VerificationKey(kid, time.Now())
+		if key == nil {
+			return nil, fmt.Errorf("unknown kid %s", kid)
+		}
+
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for kid %s", token.Method.Alg(), kid)
+		}
+		return key.PublicKey, nil
+	}
+
+	secret := j.secret
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || secret == "" {
+		return nil, fmt.Errorf("unexpected signing method %s without kid", token.Method.Alg())
+	}
+
+	now := time.Now()
+	if j.keys.SigningKey(now) != nil && !now.Before(j.secretExpireAt) {
+		return nil, errors.New("token without kid after an asymmetric key became active")
+	}
+
+	return []byte(secret), nil
 }
@@ -0,0 +1,253 @@
+package helper
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pajri/personal-backend/config"
+)
+
+// SigningMethodEdDSA adds Ed25519 signatures to jwt-go, which only ships HMAC, RSA and ECDSA.
+type SigningMethodEdDSA struct{}
+
+var EdDSASigningMethod = &SigningMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(EdDSASigningMethod.Alg(), func() jwt.SigningMethod {
+		return EdDSASigningMethod
+	})
+}
+
+func (m *SigningMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return errors.New("ed25519 signature is invalid")
+	}
+	return nil
+}
+
+func (m *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// JWTKey is one asymmetric key of the key set. Keys without a private key can only verify.
+type JWTKey struct {
+	KID        string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+	ActivateAt time.Time
+	ExpireAt   time.Time
+}
+
+// JWTKeySet holds every configured key. Rotation is scheduled through ActivateAt and ExpireAt:
+// a key is published in the JWKS and accepted for verification until it expires, and the most
+// recently activated key with a private key signs new tokens.
+type JWTKeySet struct {
+	keys []JWTKey
+}
+
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewJWTKeySet(keyConfigs []config.JWTKeyConfig) (*JWTKeySet, error) {
+	keySet := new(JWTKeySet)
+	for _, keyConfig := range keyConfigs {
+		key, err := loadJWTKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("key %s : %w", keyConfig.KID, err)
+		}
+
+		keySet.keys = append(keySet.keys, *key)
+	}
+
+	//newest activation first, so the signing key is the first usable one
+	sort.SliceStable(keySet.keys, func(i, j int) bool {
+		return keySet.keys[i].ActivateAt.After(keySet.keys[j].ActivateAt)
+	})
+
+	return keySet, nil
+}
+
+// SigningKey returns the key that signs new tokens, or nil when there is no active private key.
+func (ks *JWTKeySet) SigningKey(now time.Time) *JWTKey {
+	if ks == nil {
+		return nil
+	}
+
+	for i := range ks.keys {
+		key := &ks.keys[i]
+		if key.PrivateKey != nil && !key.ActivateAt.After(now) && !key.isExpired(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+func (ks *JWTKeySet) VerificationKey(kid string, now time.Time) *JWTKey {
+	if ks == nil {
+		return nil
+	}
+
+	for i := range ks.keys {
+		key := &ks.keys[i]
+		if key.KID == kid && !key.isExpired(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// JWKS returns the public part of every key that has not expired, including keys that are
+// scheduled but not active yet, so verifiers already know them when signing switches over.
+func (ks *JWTKeySet) JWKS(now time.Time) JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	if ks == nil {
+		return jwks
+	}
+
+	for _, key := range ks.keys {
+		if key.isExpired(now) {
+			continue
+		}
+
+		jwk := JWK{Kid: key.KID, Alg: key.Method.Alg(), Use: "sig"}
+		switch publicKey := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func (k JWTKey) isExpired(now time.Time) bool {
+	return !k.ExpireAt.IsZero() && !now.Before(k.ExpireAt)
+}
+
+func loadJWTKey(keyConfig config.JWTKeyConfig) (*JWTKey, error) {
+	if keyConfig.KID == "" {
+		return nil, errors.New("kid is required")
+	}
+
+	key := &JWTKey{
+		KID:        keyConfig.KID,
+		ActivateAt: keyConfig.ActivateAt,
+		ExpireAt:   keyConfig.ExpireAt,
+	}
+
+	if keyConfig.PrivateKeyFile != "" {
+		block, err := readPEM(keyConfig.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse private key : %w", err)
+			}
+		}
+
+		switch privateKey := privateKey.(type) {
+		case *rsa.PrivateKey:
+			key.PrivateKey = privateKey
+			key.PublicKey = &privateKey.PublicKey
+		case ed25519.PrivateKey:
+			key.PrivateKey = privateKey
+			key.PublicKey = privateKey.Public()
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+		}
+	} else if keyConfig.PublicKeyFile != "" {
+		block, err := readPEM(keyConfig.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse public key : %w", err)
+			}
+		}
+		key.PublicKey = publicKey
+	} else {
+		return nil, errors.New("either PrivateKeyFile or PublicKeyFile is required")
+	}
+
+	switch key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = EdDSASigningMethod
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key.PublicKey)
+	}
+
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+	return block, nil
+}
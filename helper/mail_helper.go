@@ -1,63 +1,138 @@
 package helper
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"net/smtp"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
 	"strings"
+	"time"
 
 	"github.com/pajri/personal-backend/adapter/cerror"
 	"github.com/pajri/personal-backend/config"
-	"github.com/pajri/personal-backend/global"
 )
 
+const (
+	MAIL_TRANSPORT_SMTP = "smtp"
+	MAIL_TRANSPORT_FILE = "file"
+	MAIL_TRANSPORT_LOG  = "log"
+	MAIL_TRANSPORT_NOOP = "noop"
+)
+
+// IEMail sends an email rendered from one of the templates in templates/email. locale is
+// the preferred language of the recipient, data is passed to the template as is.
 type IEMail interface {
-	SendMail(to []string, subject, body string) error
+	SendMail(ctx context.Context, to []string, locale, templateName string, data interface{}) error
 }
 
-type Email struct {
-	Mime        string
-	ContentType string
+// IMailSender delivers an email that has already been rendered.
+type IMailSender interface {
+	Send(to []string, content *EmailContent) error
 }
 
-func NewEmailHelper() IEMail {
-	return Email{
-		Mime:        "MIME-version: 1.0",
-		ContentType: "Content-Type: text/html; charset=UTF-8",
+// NewMailSender returns the transport chosen by Mail.Transport, smtp when it is empty.
+func NewMailSender(mailConfig config.MailConfig, smtpConfig config.SMTP) (IMailSender, error) {
+	switch mailConfig.Transport {
+	case "", MAIL_TRANSPORT_SMTP:
+		return NewSMTPSender(smtpConfig)
+	case MAIL_TRANSPORT_FILE:
+		return NewFileSender(mailConfig.Directory, smtpConfig.From)
+	case MAIL_TRANSPORT_LOG:
+		return LogSender{}, nil
+	case MAIL_TRANSPORT_NOOP:
+		return NoopSender{}, nil
+	default:
+		return nil, fmt.Errorf("mail transport %s is not supported", mailConfig.Transport)
 	}
 }
 
-func (e Email) SendMail(to []string, subject, body string) error {
-	auth := e.auth()
-	address := e.smtpAddress()
-	from := config.Config.SMTP.From
-	message := e.message(from, to, subject, body)
+// buildMessage builds a multipart/alternative message with the text body first and the html body
+// last, so clients that can show html pick it and the others fall back to text.
+func buildMessage(from string, to []string, content *EmailContent) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
 
-	err := smtp.SendMail(address, auth, from, to, []byte(message))
+	err := writeMessagePart(writer, "text/plain; charset=UTF-8", content.TextBody)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("SMM00", err, global.FRIENDLY_MESSAGE)
+		return nil, err
 	}
 
-	return nil
-}
+	err = writeMessagePart(writer, "text/html; charset=UTF-8", content.HTMLBody)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	toList := make([]string, len(to))
+	for i, address := range to {
+		toList[i] = (&mail.Address{Address: address}).String()
+	}
 
-func (e Email) smtpAddress() string {
-	address := fmt.Sprintf("%s:%v", config.Config.SMTP.Host, config.Config.SMTP.Port)
-	return address
+	var message bytes.Buffer
+	headers := []struct{ key, value string }{
+		{"From", (&mail.Address{Address: from}).String()},
+		{"To", strings.Join(toList, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", content.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(from)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": writer.Boundary()})},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", header.key, header.value)
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
 }
 
-func (e Email) auth() smtp.Auth {
-	return smtp.PlainAuth("",
-		config.Config.SMTP.Username,
-		config.Config.SMTP.Password,
-		config.Config.SMTP.Host)
+func writeMessagePart(writer *multipart.Writer, contentType, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	encoder := quotedprintable.NewWriter(part)
+	_, err = encoder.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
 }
 
-func (e Email) message(from string, to []string, subject, body string) string {
-	toList := strings.Join(to, ",")
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
 
-	message := fmt.Sprintf("%s\r\n%s\r\nFrom: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
-		e.Mime, e.ContentType, from, toList, subject, body)
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)
+}
+
+// IsPermanentMailError reports whether the smtp server rejected the email with a 5xx reply,
+// which will not succeed when it is sent again.
+func IsPermanentMailError(err error) bool {
+	if cerr, ok := err.(cerror.Error); ok {
+		err = cerr.Err
+	}
 
-	fmt.Println(message)
-	return message
+	protoErr, ok := err.(*textproto.Error)
+	return ok && protoErr.Code >= 500
 }
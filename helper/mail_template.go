@@ -0,0 +1,145 @@
+package helper
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	emailTemplateRoot   = "templates/email"
+	htmlTemplateSuffix  = ".html.tmpl"
+	textTemplateSuffix  = ".txt.tmpl"
+	partialTemplateName = "partials"
+)
+
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+// EmailContent is a rendered email, ready to be put into a message.
+type EmailContent struct {
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// EmailTemplates renders the templates in templates/email. Every locale has its own directory
+// with a text and an html file per template, plus partials shared by the templates of that locale.
+// The text file also defines the subject. Both are rendered inside the layout of their format.
+type EmailTemplates struct {
+	defaultLocale string
+	html          map[string]*htmltemplate.Template
+	text          map[string]*texttemplate.Template
+}
+
+func NewEmailTemplates(defaultLocale string) (*EmailTemplates, error) {
+	templates := &EmailTemplates{
+		defaultLocale: defaultLocale,
+		html:          make(map[string]*htmltemplate.Template),
+		text:          make(map[string]*texttemplate.Template),
+	}
+
+	locales, err := fs.ReadDir(emailTemplateFS, emailTemplateRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, locale := range locales {
+		if !locale.IsDir() {
+			continue
+		}
+
+		localeDir := path.Join(emailTemplateRoot, locale.Name())
+		files, err := fs.ReadDir(emailTemplateFS, localeDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			name := strings.TrimSuffix(file.Name(), textTemplateSuffix)
+			if name == file.Name() || name == partialTemplateName {
+				continue
+			}
+
+			key := templates.key(locale.Name(), name)
+			templates.text[key], err = texttemplate.ParseFS(emailTemplateFS,
+				path.Join(emailTemplateRoot, "layout"+textTemplateSuffix),
+				path.Join(localeDir, partialTemplateName+textTemplateSuffix),
+				path.Join(localeDir, name+textTemplateSuffix))
+			if err != nil {
+				return nil, err
+			}
+
+			templates.html[key], err = htmltemplate.ParseFS(emailTemplateFS,
+				path.Join(emailTemplateRoot, "layout"+htmlTemplateSuffix),
+				path.Join(localeDir, partialTemplateName+htmlTemplateSuffix),
+				path.Join(localeDir, name+htmlTemplateSuffix))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(templates.text) == 0 {
+		return nil, fmt.Errorf("no email templates found in %s", emailTemplateRoot)
+	}
+
+	return templates, nil
+}
+
+// Render renders the template in the given locale. A locale like "id-ID" falls back to "id"
+// and then to the default locale when there is no template for it.
+func (et *EmailTemplates) Render(locale, name string, data interface{}) (*EmailContent, error) {
+	key := et.resolve(locale, name)
+	if key == "" {
+		return nil, fmt.Errorf("email template %s is not found", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	err := et.text[key].ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = et.text[key].ExecuteTemplate(&text, "layout", data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = et.html[key].ExecuteTemplate(&html, "layout", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmailContent{
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: strings.TrimSpace(text.String()) + "\n",
+		HTMLBody: html.String(),
+	}, nil
+}
+
+func (et *EmailTemplates) resolve(locale, name string) string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, et.defaultLocale)
+
+	for _, candidate := range candidates {
+		key := et.key(candidate, name)
+		if _, ok := et.text[key]; ok {
+			return key
+		}
+	}
+	return ""
+}
+
+func (et *EmailTemplates) key(locale, name string) string {
+	return locale + "/" + name
+}
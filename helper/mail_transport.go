@@ -0,0 +1,258 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/adapter/logger"
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/global"
+)
+
+const (
+	SMTP_SECURITY_STARTTLS = "starttls"
+	SMTP_SECURITY_TLS      = "tls"
+
+	SMTP_AUTH_PLAIN    = "plain"
+	SMTP_AUTH_LOGIN    = "login"
+	SMTP_AUTH_CRAM_MD5 = "cram-md5"
+	SMTP_AUTH_NONE     = "none"
+
+	SMTP_DIAL_TIMEOUT = 30 * time.Second
+	SMTP_SEND_TIMEOUT = 2 * time.Minute
+)
+
+// SMTPSender sends through an smtp server. Security is empty to upgrade with STARTTLS only when
+// the server offers it, "starttls" to require it, or "tls" for implicit TLS, usually on port 465.
+type SMTPSender struct {
+	config config.SMTP
+}
+
+// FileSender writes every email as an .eml file into a directory instead of sending it. From is
+// the SMTP.From address, so the files look like the emails that would have been sent.
+type FileSender struct {
+	Directory string
+	From      string
+}
+
+// LogSender writes the recipients and subject of every email to the log instead of sending it.
+type LogSender struct{}
+
+// NoopSender drops every email.
+type NoopSender struct{}
+
+func NewSMTPSender(smtpConfig config.SMTP) (IMailSender, error) {
+	switch smtpConfig.Security {
+	case "", SMTP_SECURITY_STARTTLS, SMTP_SECURITY_TLS:
+	default:
+		return nil, fmt.Errorf("smtp security %s is not supported", smtpConfig.Security)
+	}
+
+	switch strings.ToLower(smtpConfig.Auth) {
+	case "", SMTP_AUTH_PLAIN, SMTP_AUTH_LOGIN, SMTP_AUTH_CRAM_MD5, SMTP_AUTH_NONE:
+	default:
+		return nil, fmt.Errorf("smtp auth %s is not supported", smtpConfig.Auth)
+	}
+
+	return SMTPSender{config: smtpConfig}, nil
+}
+
+func (s SMTPSender) Send(to []string, content *EmailContent) error {
+	message, err := buildMessage(s.config.From, to, content)
+	if err != nil {
+		return cerror.NewAndPrintWithTag("SMS00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	client, err := s.dial()
+	if err != nil {
+		return cerror.NewAndPrintWithTag("SMS01", err, global.FRIENDLY_MESSAGE)
+	}
+	defer client.Close()
+
+	if s.config.Security != SMTP_SECURITY_TLS {
+		ok, _ := client.Extension("STARTTLS")
+		if ok {
+			err = client.StartTLS(s.tlsConfig())
+			if err != nil {
+				return cerror.NewAndPrintWithTag("SMS02", err, global.FRIENDLY_MESSAGE)
+			}
+		} else if s.config.Security == SMTP_SECURITY_STARTTLS {
+			return cerror.NewAndPrintWithTag("SMS03", errors.New("smtp server does not support STARTTLS"), global.FRIENDLY_MESSAGE)
+		}
+	}
+
+	auth := s.auth()
+	if auth != nil {
+		err = client.Auth(auth)
+		if err != nil {
+			return cerror.NewAndPrintWithTag("SMS04", err, global.FRIENDLY_MESSAGE)
+		}
+	}
+
+	err = client.Mail(s.config.From)
+	if err != nil {
+		return cerror.NewAndPrintWithTag("SMS05", err, global.FRIENDLY_MESSAGE)
+	}
+
+	for _, address := range to {
+		err = client.Rcpt(address)
+		if err != nil {
+			return cerror.NewAndPrintWithTag("SMS06", err, global.FRIENDLY_MESSAGE)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return cerror.NewAndPrintWithTag("SMS07", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = writer.Write(message)
+	if err != nil {
+		return cerror.NewAndPrintWithTag("SMS08", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return cerror.NewAndPrintWithTag("SMS09", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return client.Quit()
+}
+
+func (s SMTPSender) dial() (*smtp.Client, error) {
+	address := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := &net.Dialer{Timeout: SMTP_DIAL_TIMEOUT}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.config.Security == SMTP_SECURITY_TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, s.tlsConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//bounds the whole send, a server that stops answering would hold the worker forever
+	err = conn.SetDeadline(time.Now().Add(SMTP_SEND_TIMEOUT))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (s SMTPSender) tlsConfig() *tls.Config {
+	return &tls.Config{
+		ServerName:         s.config.Host,
+		InsecureSkipVerify: s.config.InsecureSkipVerify,
+	}
+}
+
+// auth returns nil when there is nothing to authenticate with. Plain auth is the default, which
+// net/smtp only sends over TLS or to localhost.
+func (s SMTPSender) auth() smtp.Auth {
+	mechanism := strings.ToLower(s.config.Auth)
+	if mechanism == SMTP_AUTH_NONE || (s.config.Username == "" && s.config.Password == "") {
+		return nil
+	}
+
+	switch mechanism {
+	case SMTP_AUTH_LOGIN:
+		return loginAuth{username: s.config.Username, password: s.config.Password}
+	case SMTP_AUTH_CRAM_MD5:
+		return smtp.CRAMMD5Auth(s.config.Username, s.config.Password)
+	default:
+		return smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+}
+
+// loginAuth is the LOGIN mechanism, which net/smtp does not have but some servers still require.
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge %q", fromServer)
+	}
+}
+
+func NewFileSender(directory, from string) (IMailSender, error) {
+	if directory == "" {
+		return nil, errors.New("mail directory is required for the file transport")
+	}
+
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return FileSender{Directory: directory, From: from}, nil
+}
+
+func (f FileSender) Send(to []string, content *EmailContent) error {
+	message, err := buildMessage(f.From, to, content)
+	if err != nil {
+		return cerror.NewAndPrintWithTag("SMF00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	//the time keeps the files in the order they were sent, the random part keeps them apart within a second
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405Z"), hex.EncodeToString(suffix))
+
+	err = os.WriteFile(filepath.Join(f.Directory, name), message, 0644)
+	if err != nil {
+		return cerror.NewAndPrintWithTag("SMF01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
+
+func (l LogSender) Send(to []string, content *EmailContent) error {
+	//the body is left out, it holds the links with reset and verification tokens
+	logger.Entry().WithField(logger.TAG_FIELD, "SML00").WithField("to", to).Info("email : ", content.Subject)
+	return nil
+}
+
+func (n NoopSender) Send(to []string, content *EmailContent) error {
+	return nil
+}
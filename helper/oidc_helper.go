@@ -0,0 +1,408 @@
+package helper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/global"
+)
+
+const OIDC_HTTP_TIMEOUT = 10 * time.Second
+
+type IOIDC interface {
+	AuthCodeURL(ctx context.Context, provider, state, nonce, codeVerifier string) (string, error)
+	Exchange(ctx context.Context, provider, code, codeVerifier, nonce string) (*OIDCIdentity, error)
+}
+
+// OIDCIdentity is the subset of the ID token (and userinfo) claims used to link an account.
+type OIDCIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type OIDC struct {
+	providers map[string]*oidcProvider
+	client    *http.Client
+}
+
+type oidcProvider struct {
+	name   string
+	config config.OIDCProviderConfig
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+}
+
+type oidcJWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func NewOIDCHelper(providerConfigs map[string]config.OIDCProviderConfig) IOIDC {
+	providers := make(map[string]*oidcProvider)
+	for name, providerConfig := range providerConfigs {
+		providers[name] = &oidcProvider{name: name, config: providerConfig}
+	}
+
+	return &OIDC{
+		providers: providers,
+		client:    &http.Client{Timeout: OIDC_HTTP_TIMEOUT},
+	}
+}
+
+// AuthCodeURL builds the authorization endpoint URL for the authorization code flow with PKCE (S256).
+func (o *OIDC) AuthCodeURL(ctx context.Context, provider, state, nonce, codeVerifier string) (string, error) {
+	p, err := o.provider(provider)
+	if err != nil {
+		return "", err
+	}
+
+	discovery, err := o.discover(ctx, p)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code, verifies the ID token signature and claims
+// and returns the identity of the user.
+func (o *OIDC) Exchange(ctx context.Context, provider, code, codeVerifier, nonce string) (*OIDCIdentity, error) {
+	p, err := o.provider(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery, err := o.discover(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	/*start token request*/
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "EXO00", err, global.FRIENDLY_OIDC_FAILED)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	var tokenResponse oidcTokenResponse
+	err = o.doJSON(request, &tokenResponse)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "EXO01", err, global.FRIENDLY_OIDC_FAILED)
+	}
+
+	if tokenResponse.IDToken == "" {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "EXO02", errors.New("token response has no id_token"), global.FRIENDLY_OIDC_FAILED)
+	}
+	/*end token request*/
+
+	/*start verify id token*/
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokenResponse.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return o.verificationKey(ctx, p, discovery, kid, token.Method)
+	})
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "EXO03", err, global.FRIENDLY_OIDC_FAILED)
+	}
+
+	if !claims.VerifyIssuer(discovery.Issuer, true) {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "EXO04", fmt.Errorf("unexpected issuer %v", claims["iss"]), global.FRIENDLY_OIDC_FAILED)
+	}
+
+	if !o.hasAudience(claims, p.config.ClientID) {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "EXO05", fmt.Errorf("unexpected audience %v", claims["aud"]), global.FRIENDLY_OIDC_FAILED)
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "EXO06", errors.New("nonce mismatch"), global.FRIENDLY_OIDC_FAILED)
+	}
+	/*end verify id token*/
+
+	identity := &OIDCIdentity{Provider: p.name}
+	o.populateIdentity(identity, claims)
+
+	//some providers only put the email in the userinfo response
+	if identity.Email == "" && discovery.UserinfoEndpoint != "" && tokenResponse.AccessToken != "" {
+		userinfo, err := o.userinfo(ctx, discovery, tokenResponse.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+
+		if sub, _ := userinfo["sub"].(string); sub == identity.Subject {
+			o.populateIdentity(identity, userinfo)
+		}
+	}
+
+	if identity.Subject == "" {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "EXO07", errors.New("id token has no subject"), global.FRIENDLY_OIDC_FAILED)
+	}
+
+	return identity, nil
+}
+
+func (o *OIDC) provider(name string) (*oidcProvider, error) {
+	p, ok := o.providers[name]
+	if !ok {
+		cerr := cerror.NewAndPrintWithTag("OPR00", fmt.Errorf("oidc provider %s is not configured", name), global.FRIENDLY_OIDC_UNKNOWN_PROVIDER)
+		cerr.Type = cerror.TYPE_NOT_FOUND
+		return nil, cerr
+	}
+	return p, nil
+}
+
+func (o *OIDC) discover(ctx context.Context, p *oidcProvider) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	discoveryURL := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "DSO00", err, global.FRIENDLY_OIDC_FAILED)
+	}
+
+	discovery := new(oidcDiscovery)
+	err = o.doJSON(request, discovery)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "DSO01", err, global.FRIENDLY_OIDC_FAILED)
+	}
+
+	if discovery.Issuer != strings.TrimSuffix(p.config.Issuer, "/") && discovery.Issuer != p.config.Issuer {
+		err = fmt.Errorf("discovery issuer %s does not match configured issuer %s", discovery.Issuer, p.config.Issuer)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "DSO02", err, global.FRIENDLY_OIDC_FAILED)
+	}
+
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// verificationKey returns the JWKS key for kid. The key set is fetched again when kid
+// is unknown, which is how providers announce key rotation.
+func (o *OIDC) verificationKey(ctx context.Context, p *oidcProvider, discovery *oidcDiscovery, kid string, method jwt.SigningMethod) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		keys, err := o.fetchKeys(ctx, discovery.JwksURI)
+		if err != nil {
+			return nil, err
+		}
+		p.keys = keys
+
+		key, ok = p.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("no key found for kid %s", kid)
+		}
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if _, ok := method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s for rsa key", method.Alg())
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s for ec key", method.Alg())
+		}
+	}
+
+	return key, nil
+}
+
+func (o *OIDC) fetchKeys(ctx context.Context, jwksURI string) (map[string]interface{}, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	err = o.doJSON(request, &jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		switch jwk.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				continue
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			if jwk.Crv != "P-256" {
+				continue
+			}
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil {
+				continue
+			}
+			y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if err != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+func (o *OIDC) userinfo(ctx context.Context, discovery *oidcDiscovery, accessToken string) (map[string]interface{}, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "UIO00", err, global.FRIENDLY_OIDC_FAILED)
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+
+	userinfo := make(map[string]interface{})
+	err = o.doJSON(request, &userinfo)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "UIO01", err, global.FRIENDLY_OIDC_FAILED)
+	}
+
+	return userinfo, nil
+}
+
+func (o *OIDC) doJSON(request *http.Request, target interface{}) error {
+	response, err := o.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returned status %d", request.Method, request.URL.Redacted(), response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+func (o *OIDC) hasAudience(claims jwt.MapClaims, clientID string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, elem := range aud {
+			if elem == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (o *OIDC) populateIdentity(identity *OIDCIdentity, claims map[string]interface{}) {
+	if sub, ok := claims["sub"].(string); ok && identity.Subject == "" {
+		identity.Subject = sub
+	}
+
+	if email, ok := claims["email"].(string); ok && email != "" {
+		identity.Email = email
+	}
+
+	//email_verified is a boolean in the spec, but some providers send it as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	if name, ok := claims["name"].(string); ok && name != "" {
+		identity.Name = name
+	}
+}
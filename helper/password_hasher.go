@@ -0,0 +1,176 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/global"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DEFAULT_ARGON2_MEMORY      = 64 * 1024
+	DEFAULT_ARGON2_ITERATIONS  = 3
+	DEFAULT_ARGON2_PARALLELISM = 2
+	ARGON2_SALT_LENGTH         = 16
+	ARGON2_KEY_LENGTH          = 32
+)
+
+// IPasswordHasher hashes passwords into a self describing string. Hashes of every supported
+// algorithm can be verified, NeedsRehash tells whether a hash should be replaced by a new one
+// created with the current algorithm and parameters.
+type IPasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encodedHash string, legacySalt []byte) (bool, error)
+	NeedsRehash(encodedHash string) bool
+}
+
+// PasswordHasher creates argon2id hashes in the PHC string format
+// ($argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>) and still verifies the bcrypt hashes
+// of password+salt that accounts were created with before.
+type PasswordHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func NewPasswordHasher(hashConfig config.PasswordHashConfig) IPasswordHasher {
+	hasher := PasswordHasher{
+		Memory:      hashConfig.Memory,
+		Iterations:  hashConfig.Iterations,
+		Parallelism: hashConfig.Parallelism,
+	}
+
+	if hasher.Memory == 0 {
+		hasher.Memory = DEFAULT_ARGON2_MEMORY
+	}
+
+	if hasher.Iterations == 0 {
+		hasher.Iterations = DEFAULT_ARGON2_ITERATIONS
+	}
+
+	if hasher.Parallelism == 0 {
+		hasher.Parallelism = DEFAULT_ARGON2_PARALLELISM
+	}
+
+	return hasher
+}
+
+func (ph PasswordHasher) Hash(password string) (string, error) {
+	salt := make([]byte, ARGON2_SALT_LENGTH)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return "", cerror.NewAndPrintWithTag("PHH00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, ph.Iterations, ph.Memory, ph.Parallelism, ARGON2_KEY_LENGTH)
+
+	encodedHash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, ph.Memory, ph.Iterations, ph.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+	return encodedHash, nil
+}
+
+// Verify compares password with encodedHash. legacySalt is only used for bcrypt hashes,
+// which were created from the password with the salt of the account appended.
+func (ph PasswordHasher) Verify(password, encodedHash string, legacySalt []byte) (bool, error) {
+	if ph.isBcrypt(encodedHash) {
+		var saltedPassword []byte
+		saltedPassword = append(saltedPassword, password...)
+		saltedPassword = append(saltedPassword, legacySalt...)
+
+		err := bcrypt.CompareHashAndPassword([]byte(encodedHash), saltedPassword)
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, cerror.NewAndPrintWithTag("PHV00", err, global.FRIENDLY_MESSAGE)
+		}
+		return true, nil
+	}
+
+	params, err := ph.decodeArgon2(encodedHash)
+	if err != nil {
+		return false, cerror.NewAndPrintWithTag("PHV01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+func (ph PasswordHasher) NeedsRehash(encodedHash string) bool {
+	params, err := ph.decodeArgon2(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return params.memory != ph.Memory ||
+		params.iterations != ph.Iterations ||
+		params.parallelism != ph.Parallelism ||
+		len(params.salt) != ARGON2_SALT_LENGTH ||
+		len(params.key) != ARGON2_KEY_LENGTH
+}
+
+func (ph PasswordHasher) isBcrypt(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+func (ph PasswordHasher) decodeArgon2(encodedHash string) (*argon2Params, error) {
+	//"", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errors.New("hash is not an argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != argon2.Version {
+		return nil, fmt.Errorf("argon2 version %d is not supported", version)
+	}
+
+	params := new(argon2Params)
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, err
+	}
+
+	params.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params.key) == 0 {
+		return nil, errors.New("argon2id hash has an empty key")
+	}
+
+	return params, nil
+}
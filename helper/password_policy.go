@@ -0,0 +1,241 @@
+package helper
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/global"
+)
+
+const (
+	DEFAULT_PASSWORD_MIN_LENGTH   = 10
+	DEFAULT_PASSWORD_MAX_LENGTH   = 128
+	DEFAULT_PASSWORD_MIN_SCORE    = PASSWORD_SCORE_STRONG
+	BREACHED_PASSWORD_PREFIX_SIZE = 5
+	minPersonalInfoLength         = 3
+)
+
+// IPasswordPolicy checks a new password and returns the rules it breaks as friendly messages.
+// userInputs are the email, name and the like of the account, which must not be part of the password.
+type IPasswordPolicy interface {
+	Check(password string, userInputs ...string) []string
+}
+
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	MinScore         int
+	breached         *BreachedPasswords
+}
+
+// BreachedPasswords is an offline copy of a breached password list, kept the way the
+// k-anonymity range API serves it : SHA-1 hashes grouped by their first 5 hex characters.
+type BreachedPasswords struct {
+	buckets map[string]map[string]struct{}
+}
+
+func NewPasswordPolicy(policyConfig config.PasswordPolicyConfig) (IPasswordPolicy, error) {
+	policy := PasswordPolicy{
+		MinLength:        policyConfig.MinLength,
+		MaxLength:        policyConfig.MaxLength,
+		RequireUppercase: policyConfig.RequireUppercase,
+		RequireLowercase: policyConfig.RequireLowercase,
+		RequireDigit:     policyConfig.RequireDigit,
+		RequireSymbol:    policyConfig.RequireSymbol,
+		MinScore:         policyConfig.MinStrengthScore,
+	}
+
+	if policy.MinLength == 0 {
+		policy.MinLength = DEFAULT_PASSWORD_MIN_LENGTH
+	}
+
+	if policy.MaxLength == 0 {
+		policy.MaxLength = DEFAULT_PASSWORD_MAX_LENGTH
+	}
+
+	if policy.MinScore == 0 {
+		policy.MinScore = DEFAULT_PASSWORD_MIN_SCORE
+	}
+
+	if policyConfig.BreachedPasswordFile != "" {
+		breached, err := LoadBreachedPasswords(policyConfig.BreachedPasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.breached = breached
+	}
+
+	return policy, nil
+}
+
+func (pp PasswordPolicy) Check(password string, userInputs ...string) []string {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if length < pp.MinLength {
+		violations = append(violations, fmt.Sprintf(global.FRIENDLY_PASSWORD_TOO_SHORT, pp.MinLength))
+	}
+
+	//the strength estimate is O(n³), so a long password is turned down before it
+	if length > pp.MaxLength {
+		return []string{fmt.Sprintf(global.FRIENDLY_PASSWORD_TOO_LONG, pp.MaxLength)}
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+
+	if pp.RequireUppercase && !hasUpper {
+		violations = append(violations, global.FRIENDLY_PASSWORD_NEED_UPPERCASE)
+	}
+
+	if pp.RequireLowercase && !hasLower {
+		violations = append(violations, global.FRIENDLY_PASSWORD_NEED_LOWERCASE)
+	}
+
+	if pp.RequireDigit && !hasDigit {
+		violations = append(violations, global.FRIENDLY_PASSWORD_NEED_DIGIT)
+	}
+
+	if pp.RequireSymbol && !hasSymbol {
+		violations = append(violations, global.FRIENDLY_PASSWORD_NEED_SYMBOL)
+	}
+
+	personalInfo := pp.personalInfo(userInputs)
+	lowerPassword := strings.ToLower(password)
+	for _, info := range personalInfo {
+		if strings.Contains(lowerPassword, info) {
+			violations = append(violations, global.FRIENDLY_PASSWORD_PERSONAL_INFO)
+			break
+		}
+	}
+
+	if pp.MinScore > 0 && EstimatePasswordStrength(password, personalInfo...).Score < pp.MinScore {
+		violations = append(violations, global.FRIENDLY_PASSWORD_TOO_WEAK)
+	}
+
+	if pp.breached.Contains(password) {
+		violations = append(violations, global.FRIENDLY_PASSWORD_BREACHED)
+	}
+
+	return violations
+}
+
+// personalInfo splits the user inputs into the lowercased parts a user would put in a password,
+// for example the local part of the email and every word of the name.
+func (pp PasswordPolicy) personalInfo(userInputs []string) []string {
+	var parts []string
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		candidates := []string{input}
+
+		if at := strings.LastIndex(input, "@"); at > 0 {
+			//the domain is shared with a lot of other accounts, only the local part is personal
+			input = input[:at]
+			candidates = append(candidates, input)
+		}
+
+		candidates = append(candidates, strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= minPersonalInfoLength {
+				parts = append(parts, candidate)
+			}
+		}
+	}
+	return parts
+}
+
+// LoadBreachedPasswords reads a file with one uppercase or lowercase SHA-1 hash per line, optionally
+// followed by ":<count>" like the downloadable Pwned Passwords list. Empty lines and lines starting
+// with # are skipped.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	breached := &BreachedPasswords{buckets: make(map[string]map[string]struct{})}
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash := strings.ToUpper(strings.SplitN(line, ":", 2)[0])
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s line %d is not a SHA-1 hash", path, lineNumber)
+		}
+
+		_, err := hex.DecodeString(hash)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d is not a SHA-1 hash", path, lineNumber)
+		}
+
+		prefix, suffix := hash[:BREACHED_PASSWORD_PREFIX_SIZE], hash[BREACHED_PASSWORD_PREFIX_SIZE:]
+		bucket, ok := breached.buckets[prefix]
+		if !ok {
+			bucket = make(map[string]struct{})
+			breached.buckets[prefix] = bucket
+		}
+		bucket[suffix] = struct{}{}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return breached, nil
+}
+
+// Contains looks password up by the prefix of its hash first and then compares the suffixes
+// in that bucket, the same steps a client of the range API takes.
+func (bp *BreachedPasswords) Contains(password string) bool {
+	if bp == nil {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	bucket := bp.Range(hash[:BREACHED_PASSWORD_PREFIX_SIZE])
+	_, ok := bucket[hash[BREACHED_PASSWORD_PREFIX_SIZE:]]
+	return ok
+}
+
+// Range returns the hash suffixes that share prefix.
+func (bp *BreachedPasswords) Range(prefix string) map[string]struct{} {
+	if bp == nil {
+		return nil
+	}
+	return bp.buckets[strings.ToUpper(prefix)]
+}
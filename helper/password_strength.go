@@ -0,0 +1,323 @@
+package helper
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	PASSWORD_SCORE_VERY_WEAK   = 0
+	PASSWORD_SCORE_WEAK        = 1
+	PASSWORD_SCORE_FAIR        = 2
+	PASSWORD_SCORE_STRONG      = 3
+	PASSWORD_SCORE_VERY_STRONG = 4
+
+	//every character not covered by a pattern is assumed to be one of this many
+	bruteforceCardinality = 10
+	minPatternLength      = 3
+	minYearSpace          = 20
+)
+
+// score thresholds in log10 of guesses, the same ones zxcvbn uses
+var passwordScoreThresholds = []float64{3, 6, 8, 10}
+
+var leetSubstitutions = map[rune]rune{
+	'4': 'a',
+	'@': 'a',
+	'8': 'b',
+	'(': 'c',
+	'3': 'e',
+	'6': 'g',
+	'1': 'i',
+	'!': 'i',
+	'|': 'l',
+	'0': 'o',
+	'$': 's',
+	'5': 's',
+	'7': 't',
+	'2': 'z',
+}
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"qazwsxedcrfvtgbyhnujmik,ol.p;/",
+}
+
+// commonPasswords is ordered by frequency, the position of a word is its guess rank.
+var commonPasswords = []string{
+	"password", "123456", "12345678", "qwerty", "123456789", "12345", "1234", "111111",
+	"1234567", "dragon", "123123", "baseball", "abc123", "football", "monkey", "letmein",
+	"696969", "shadow", "master", "666666", "qwertyuiop", "123321", "mustang", "1234567890",
+	"michael", "654321", "superman", "1qaz2wsx", "7777777", "121212", "000000", "qazwsx",
+	"123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter",
+	"buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou",
+	"2000", "charlie", "robert", "thomas", "hockey", "ranger", "daniel", "starwars",
+	"klaster", "112233", "george", "computer", "michelle", "jessica", "pepper", "1111",
+	"zxcvbn", "555555", "11111111", "131313", "freedom", "777777", "pass", "maggie",
+	"159753", "aaaaaa", "ginger", "princess", "joshua", "cheese", "amanda", "summer",
+	"love", "ashley", "nicole", "chelsea", "biteme", "matthew", "access", "yankees",
+	"987654321", "dallas", "austin", "thunder", "taylor", "matrix", "mobilemail", "mom",
+	"monitor", "monitoring", "montana", "moon", "moscow", "welcome", "admin", "login",
+	"passw0rd", "hello", "secret", "winter", "spring", "autumn", "flower", "orange",
+	"banana", "apple", "chocolate", "purple", "silver", "golden", "diamond", "angel",
+	"internet", "samsung", "google", "facebook", "whatever", "nothing", "mymoment", "changeme",
+	"default", "server", "system", "root", "user", "guest", "test", "demo",
+}
+
+var commonPasswordRank = func() map[string]int {
+	ranks := make(map[string]int, len(commonPasswords))
+	for i, word := range commonPasswords {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}()
+
+type PasswordStrength struct {
+	GuessesLog10 float64
+	Score        int
+}
+
+// EstimatePasswordStrength estimates how many guesses an attacker needs for password, in the
+// spirit of zxcvbn. The password is split into the cheapest sequence of known patterns (common
+// passwords, user inputs, keyboard walks, sequences, repeats and years) and brute forced characters,
+// the guesses of the parts are multiplied and mapped to a score from 0 to 4.
+func EstimatePasswordStrength(password string, userInputs ...string) PasswordStrength {
+	runes := []rune(password)
+	matches := findPasswordPatterns(runes, userInputs)
+
+	//best[k] is the smallest log10 of guesses for the first k runes
+	best := make([]float64, len(runes)+1)
+	for k := 1; k <= len(runes); k++ {
+		best[k] = best[k-1] + math.Log10(bruteforceCardinality)
+		for _, m := range matches {
+			if m.end == k && best[m.start]+m.guessesLog10 < best[k] {
+				best[k] = best[m.start] + m.guessesLog10
+			}
+		}
+	}
+
+	guessesLog10 := best[len(runes)]
+	score := PASSWORD_SCORE_VERY_STRONG
+	for i, threshold := range passwordScoreThresholds {
+		if guessesLog10 < threshold {
+			score = i
+			break
+		}
+	}
+
+	return PasswordStrength{GuessesLog10: guessesLog10, Score: score}
+}
+
+type passwordPattern struct {
+	start        int
+	end          int
+	guessesLog10 float64
+}
+
+func findPasswordPatterns(runes []rune, userInputs []string) []passwordPattern {
+	ranks := make(map[string]int, len(commonPasswordRank)+len(userInputs))
+	for word, rank := range commonPasswordRank {
+		ranks[word] = rank
+	}
+	for _, input := range userInputs {
+		ranks[strings.ToLower(input)] = 1
+	}
+
+	var patterns []passwordPattern
+	patterns = append(patterns, findDictionaryPatterns(runes, ranks)...)
+	patterns = append(patterns, findRepeatPatterns(runes)...)
+	patterns = append(patterns, findSequencePatterns(runes)...)
+	patterns = append(patterns, findKeyboardPatterns(runes)...)
+	patterns = append(patterns, findYearPatterns(runes)...)
+	return patterns
+}
+
+// dictionaryCandidate is a form of a token that is looked up in the dictionary, multiplier
+// accounts for the extra guesses of trying reversed and l33t forms.
+type dictionaryCandidate struct {
+	word       string
+	multiplier float64
+}
+
+func findDictionaryPatterns(runes []rune, ranks map[string]int) []passwordPattern {
+	var patterns []passwordPattern
+	for i := 0; i < len(runes); i++ {
+		for j := i + minPatternLength; j <= len(runes); j++ {
+			token := runes[i:j]
+			lower := strings.ToLower(string(token))
+			unleeted, isLeet := unleet(lower)
+
+			candidates := []dictionaryCandidate{
+				{lower, 1},
+				{reverseString(lower), 2},
+			}
+			if isLeet {
+				candidates = append(candidates,
+					dictionaryCandidate{unleeted, 2},
+					dictionaryCandidate{reverseString(unleeted), 4})
+			}
+
+			guesses := 0.0
+			for _, candidate := range candidates {
+				rank, ok := ranks[candidate.word]
+				if ok && (guesses == 0 || float64(rank)*candidate.multiplier < guesses) {
+					guesses = float64(rank) * candidate.multiplier
+				}
+			}
+			if guesses == 0 {
+				continue
+			}
+
+			guesses *= uppercaseVariations(token)
+			patterns = append(patterns, passwordPattern{i, j, math.Log10(guesses)})
+		}
+	}
+	return patterns
+}
+
+func findRepeatPatterns(runes []rune) []passwordPattern {
+	var patterns []passwordPattern
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && runes[j] == runes[i] {
+			j++
+		}
+		if j-i >= minPatternLength {
+			guesses := float64(runeCardinality(runes[i]) * (j - i))
+			patterns = append(patterns, passwordPattern{i, j, math.Log10(guesses)})
+		}
+		i = j
+	}
+	return patterns
+}
+
+func findSequencePatterns(runes []rune) []passwordPattern {
+	var patterns []passwordPattern
+	for i := 0; i+1 < len(runes); {
+		delta := runes[i+1] - runes[i]
+		if (delta != 1 && delta != -1) || runeCardinality(runes[i]) != runeCardinality(runes[i+1]) {
+			i++
+			continue
+		}
+
+		j := i + 2
+		for j < len(runes) && runes[j]-runes[j-1] == delta && runeCardinality(runes[j]) == runeCardinality(runes[i]) {
+			j++
+		}
+		if j-i >= minPatternLength {
+			base := float64(runeCardinality(runes[i]))
+			if strings.ContainsRune("aAzZ019", runes[i]) {
+				//obvious starting points are tried first
+				base = 4
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			patterns = append(patterns, passwordPattern{i, j, math.Log10(base * float64(j-i))})
+		}
+		i = j - 1
+	}
+	return patterns
+}
+
+func findKeyboardPatterns(runes []rune) []passwordPattern {
+	var patterns []passwordPattern
+	for i := 0; i < len(runes); i++ {
+		for j := len(runes); j >= i+minPatternLength; j-- {
+			token := strings.ToLower(string(runes[i:j]))
+			found := false
+			for _, row := range keyboardRows {
+				if strings.Contains(row, token) || strings.Contains(row, reverseString(token)) {
+					found = true
+					break
+				}
+			}
+			if found {
+				//about half of the keys can start a walk in either direction
+				guesses := 47 * float64(j-i) * uppercaseVariations(runes[i:j])
+				patterns = append(patterns, passwordPattern{i, j, math.Log10(guesses)})
+				break
+			}
+		}
+	}
+	return patterns
+}
+
+func findYearPatterns(runes []rune) []passwordPattern {
+	var patterns []passwordPattern
+	currentYear := time.Now().Year()
+	for i := 0; i+4 <= len(runes); i++ {
+		year, err := strconv.Atoi(string(runes[i : i+4]))
+		if err != nil || year < 1900 || year > 2099 {
+			continue
+		}
+
+		space := math.Abs(float64(year - currentYear))
+		if space < minYearSpace {
+			space = minYearSpace
+		}
+		patterns = append(patterns, passwordPattern{i, i + 4, math.Log10(space)})
+	}
+	return patterns
+}
+
+func uppercaseVariations(token []rune) float64 {
+	var upper, lower int
+	for _, r := range token {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+
+	//first letter, last letter or all letters capitalized are tried right after all lowercase
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(token[0]) || unicode.IsUpper(token[len(token)-1]))) {
+		return 2
+	}
+
+	return math.Pow(2, math.Min(float64(upper), float64(lower)))
+}
+
+func runeCardinality(r rune) int {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLower(r), unicode.IsUpper(r):
+		return 26
+	default:
+		return 33
+	}
+}
+
+func unleet(token string) (string, bool) {
+	isLeet := false
+	unleeted := []rune(token)
+	for i, r := range unleeted {
+		if substitute, ok := leetSubstitutions[r]; ok {
+			unleeted[i] = substitute
+			isLeet = true
+		}
+	}
+	return string(unleeted), isLeet
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -1,79 +1,253 @@
 package helper
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/pajri/personal-backend/adapter/cerror"
 	"github.com/pajri/personal-backend/config"
 	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/metrics"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// IRedis is the part of redis the app uses. ctx carries the trace the call is part of.
 type IRedis interface {
-	Set(key string, value interface{}, exp int64) error
-	Get(key string) (string, error)
-	Delete(key string) error
+	Set(ctx context.Context, key string, value interface{}, exp int64) error
+	Get(ctx context.Context, key string) (string, error)
+	Delete(ctx context.Context, key string) error
+	Incr(ctx context.Context, key string, exp int64) (int64, error)
+	GetAndDelete(ctx context.Context, key string) (string, error)
+	SetNX(ctx context.Context, key string, value interface{}, exp int64) (bool, error)
+	SAdd(ctx context.Context, key string, exp int64, members ...string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+	SRem(ctx context.Context, key string, members ...string) error
+	Ping(ctx context.Context) error
+	Close() error
 }
 
-type Redis struct {
-	Client redis.Conn
-}
+const (
+	REDIS_MAX_IDLE     = 10
+	REDIS_IDLE_TIMEOUT = 4 * time.Minute
+)
 
-var RedisHelper IRedis
+// incrScript and sAddScript change a key and its expiry together, so a key is never left without
+// the expiry when the connection drops in between. An exp of 0 keeps the key forever.
+var (
+	incrScript = redis.NewScript(1, `
+local value = redis.call('INCR', KEYS[1])
+if tonumber(ARGV[1]) ~= 0 then redis.call('EXPIREAT', KEYS[1], ARGV[1]) end
+return value`)
+	sAddScript = redis.NewScript(1, `
+redis.call('SADD', KEYS[1], unpack(ARGV, 2))
+if tonumber(ARGV[1]) ~= 0 then redis.call('EXPIREAT', KEYS[1], ARGV[1]) end
+return 0`)
+)
 
-func InitRedis() {
-	RedisHelper = NewRedisHelper()
+// Redis takes a connection from Pool for every call, so concurrent requests never share one.
+type Redis struct {
+	Pool    *redis.Pool
+	metrics *metrics.Metrics
 }
 
-func NewRedisHelper() IRedis {
+// NewRedisHelper connects to redis. Commands are timed by _metrics and every call gets a span
+// from tracer.
+func NewRedisHelper(redisConfig config.RedisConfig, _metrics *metrics.Metrics, tracer trace.Tracer) (IRedis, error) {
 	//Connect
-	var err error
-	address := fmt.Sprintf("%s:%v", config.Config.Redis.Host, config.Config.Redis.Port)
-	client, err := redis.Dial("tcp", address)
-	if err != nil {
-		log.Fatal("error redis.Dial : ", err)
+	address := fmt.Sprintf("%s:%v", redisConfig.Host, redisConfig.Port)
+	pool := &redis.Pool{
+		MaxIdle:     REDIS_MAX_IDLE,
+		IdleTimeout: REDIS_IDLE_TIMEOUT,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", address)
+		},
 	}
 
-	RedisHelper = Redis{Client: client}
+	//fail on start when redis is not there
+	conn := pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("PING")
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
 
-	// response, err := c.Do("AUTH", config.Config.Redis.Password)
+	// response, err := c.Do("AUTH", redisConfig.Password)
 	// if err != nil {
 	// 	log.Fatal("redis auth error : ", err)
 	// }
 
 	// fmt.Println("Redis connected ", response)
 
-	return RedisHelper
+	return tracedRedis{IRedis: Redis{Pool: pool, metrics: _metrics}, tracer: tracer}, nil
+}
+
+// conn takes a connection from the pool, the caller closes it to give it back
+func (rh Redis) conn(ctx context.Context) (redis.Conn, error) {
+	conn, err := rh.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rh.metrics.InstrumentRedis(conn), nil
+}
+
+// withExpireAt adds the EXAT option of SET when exp is set
+func withExpireAt(args redis.Args, exp int64) redis.Args {
+	if exp == 0 {
+		return args
+	}
+	return args.Add("EXAT", exp)
+}
+
+func (rh Redis) Ping(ctx context.Context) error {
+	conn, err := rh.conn(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "PRV01", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PING")
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "PRV00", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+func (rh Redis) Close() error {
+	return rh.Pool.Close()
 }
 
-func (rh Redis) Set(key string, value interface{}, exp int64) error {
-	_, err := rh.Client.Do("SET", key, value)
+func (rh Redis) Set(ctx context.Context, key string, value interface{}, exp int64) error {
+	conn, err := rh.conn(ctx)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("SRV00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "SRV02", err, global.FRIENDLY_MESSAGE)
 	}
+	defer conn.Close()
 
-	if exp != 0 {
-		_, err = rh.Client.Do("EXPIREAT", key, exp)
-		if err != nil {
-			return cerror.NewAndPrintWithTag("SRV01", err, global.FRIENDLY_MESSAGE)
-		}
+	_, err = conn.Do("SET", withExpireAt(redis.Args{}.Add(key, value), exp)...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "SRV00", err, global.FRIENDLY_MESSAGE)
 	}
 	return nil
 }
 
-func (rh Redis) Get(key string) (string, error) {
-	value, err := redis.String(rh.Client.Do("GET", key))
+func (rh Redis) Get(ctx context.Context, key string) (string, error) {
+	conn, err := rh.conn(ctx)
 	if err != nil {
-		return "", cerror.NewAndPrintWithTag("GRV00", err, global.FRIENDLY_MESSAGE)
+		return "", cerror.NewAndPrintWithTagContext(ctx, "GRV01", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	value, err := redis.String(conn.Do("GET", key))
+	if err != nil {
+		return "", cerror.NewAndPrintWithTagContext(ctx, "GRV00", err, global.FRIENDLY_MESSAGE)
 	}
 	return value, nil
 }
 
-func (rh Redis) Delete(key string) error {
-	_, err := rh.Client.Do("DEL", key)
+func (rh Redis) Delete(ctx context.Context, key string) error {
+	conn, err := rh.conn(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DRV01", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("DEL", key)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DRV00", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+func (rh Redis) Incr(ctx context.Context, key string, exp int64) (int64, error) {
+	conn, err := rh.conn(ctx)
+	if err != nil {
+		return 0, cerror.NewAndPrintWithTagContext(ctx, "IRV02", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	value, err := redis.Int64(incrScript.Do(conn, key, exp))
+	if err != nil {
+		return 0, cerror.NewAndPrintWithTagContext(ctx, "IRV00", err, global.FRIENDLY_MESSAGE)
+	}
+	return value, nil
+}
+
+// GetAndDelete reads and removes key in one GETDEL, so a value can only be taken once.
+func (rh Redis) GetAndDelete(ctx context.Context, key string) (string, error) {
+	conn, err := rh.conn(ctx)
+	if err != nil {
+		return "", cerror.NewAndPrintWithTagContext(ctx, "GDV00", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	value, err := redis.String(conn.Do("GETDEL", key))
+	if err != nil {
+		return "", cerror.NewAndPrintWithTagContext(ctx, "GDV01", err, global.FRIENDLY_MESSAGE)
+	}
+	return value, nil
+}
+
+// SetNX sets key only when it does not exist yet and reports whether it did. The key and its
+// expiry are set by one SET, so a key that is taken always expires.
+func (rh Redis) SetNX(ctx context.Context, key string, value interface{}, exp int64) (bool, error) {
+	conn, err := rh.conn(ctx)
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "SNV02", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	_, err = redis.String(conn.Do("SET", withExpireAt(redis.Args{}.Add(key, value, "NX"), exp)...))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "SNV00", err, global.FRIENDLY_MESSAGE)
+	}
+	return true, nil
+}
+
+// SAdd adds members to the set at key. exp replaces the expiry of the whole set.
+func (rh Redis) SAdd(ctx context.Context, key string, exp int64, members ...string) error {
+	conn, err := rh.conn(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "SAV02", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	_, err = sAddScript.Do(conn, redis.Args{}.Add(key, exp).AddFlat(members)...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "SAV00", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+func (rh Redis) SMembers(ctx context.Context, key string) ([]string, error) {
+	conn, err := rh.conn(ctx)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "SMV01", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	members, err := redis.Strings(conn.Do("SMEMBERS", key))
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "SMV00", err, global.FRIENDLY_MESSAGE)
+	}
+	return members, nil
+}
+
+func (rh Redis) SRem(ctx context.Context, key string, members ...string) error {
+	conn, err := rh.conn(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "SRM01", err, global.FRIENDLY_MESSAGE)
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("SREM", redis.Args{}.Add(key).AddFlat(members)...)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("DRV00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "SRM00", err, global.FRIENDLY_MESSAGE)
 	}
 	return nil
 }
@@ -0,0 +1,91 @@
+package helper
+
+import (
+	"context"
+
+	"github.com/pajri/personal-backend/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedRedis starts a span for every call of the IRedis it wraps. Keys are left out of the
+// spans, many of them are tokens.
+type tracedRedis struct {
+	IRedis
+	tracer trace.Tracer
+}
+
+func (r tracedRedis) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return r.tracer.Start(ctx, "redis "+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationKey.String(operation)))
+}
+
+func (r tracedRedis) Set(ctx context.Context, key string, value interface{}, exp int64) error {
+	ctx, span := r.start(ctx, "SET")
+	err := r.IRedis.Set(ctx, key, value, exp)
+	tracing.End(span, err)
+	return err
+}
+
+func (r tracedRedis) Get(ctx context.Context, key string) (string, error) {
+	ctx, span := r.start(ctx, "GET")
+	value, err := r.IRedis.Get(ctx, key)
+	tracing.End(span, err)
+	return value, err
+}
+
+func (r tracedRedis) Delete(ctx context.Context, key string) error {
+	ctx, span := r.start(ctx, "DEL")
+	err := r.IRedis.Delete(ctx, key)
+	tracing.End(span, err)
+	return err
+}
+
+func (r tracedRedis) Incr(ctx context.Context, key string, exp int64) (int64, error) {
+	ctx, span := r.start(ctx, "INCR")
+	value, err := r.IRedis.Incr(ctx, key, exp)
+	tracing.End(span, err)
+	return value, err
+}
+
+func (r tracedRedis) GetAndDelete(ctx context.Context, key string) (string, error) {
+	ctx, span := r.start(ctx, "GETDEL")
+	value, err := r.IRedis.GetAndDelete(ctx, key)
+	tracing.End(span, err)
+	return value, err
+}
+
+func (r tracedRedis) SetNX(ctx context.Context, key string, value interface{}, exp int64) (bool, error) {
+	ctx, span := r.start(ctx, "SETNX")
+	isSet, err := r.IRedis.SetNX(ctx, key, value, exp)
+	tracing.End(span, err)
+	return isSet, err
+}
+
+func (r tracedRedis) SAdd(ctx context.Context, key string, exp int64, members ...string) error {
+	ctx, span := r.start(ctx, "SADD")
+	err := r.IRedis.SAdd(ctx, key, exp, members...)
+	tracing.End(span, err)
+	return err
+}
+
+func (r tracedRedis) SMembers(ctx context.Context, key string) ([]string, error) {
+	ctx, span := r.start(ctx, "SMEMBERS")
+	members, err := r.IRedis.SMembers(ctx, key)
+	tracing.End(span, err)
+	return members, err
+}
+
+func (r tracedRedis) SRem(ctx context.Context, key string, members ...string) error {
+	ctx, span := r.start(ctx, "SREM")
+	err := r.IRedis.SRem(ctx, key, members...)
+	tracing.End(span, err)
+	return err
+}
+
+func (r tracedRedis) Ping(ctx context.Context) error {
+	ctx, span := r.start(ctx, "PING")
+	err := r.IRedis.Ping(ctx)
+	tracing.End(span, err)
+	return err
+}
@@ -36,7 +36,7 @@ func (ih ImageHandler) SaveImage(c *gin.Context) {
 	//get image
 	imageFile, err := c.FormFile("image")
 	if imageFile != nil && err != nil {
-		cerr := cerror.NewAndPrintWithTag("UIP00", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "UIP00", err, global.FRIENDLY_MESSAGE)
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusInternalServerError, response)
 		return
@@ -45,7 +45,7 @@ func (ih ImageHandler) SaveImage(c *gin.Context) {
 	/*start validate image*/
 	//validate required
 	if imageFile == nil {
-		cerr := cerror.NewAndPrintWithTag("UIP01", err, global.FRIENDLY_IMAGE_REQUIRED)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "UIP01", err, global.FRIENDLY_IMAGE_REQUIRED)
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusInternalServerError, response)
 		return
@@ -56,7 +56,7 @@ func (ih ImageHandler) SaveImage(c *gin.Context) {
 	if imageFile.Size > int64(maxSizeMB*1024*1024) {
 		msg := fmt.Sprintf(global.ERR_MAX_IMAGE_SIZE_EXCEED_LIMIT, maxSizeMB, imageFile.Size*1024*1024, email)
 		friehdly := fmt.Sprintf(global.FRIENDLY_IMAGE_SIZE_EXCEED_LIMIT, maxSizeMB)
-		cerr := cerror.NewAndPrintWithTag("UIP03", errors.New(msg), friehdly)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "UIP03", errors.New(msg), friehdly)
 
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusBadRequest, response)
@@ -72,14 +72,14 @@ func (ih ImageHandler) SaveImage(c *gin.Context) {
 	if !slice.Contains(global.AllowedMIME, imageHeader) {
 		errorMessage := fmt.Sprintf(global.ERR_IMAGE_NOT_ALLOWED, imageHeader)
 		friendlyMessage := fmt.Sprintf(global.FRIENDLY_IMAGE_NOT_ALLOWED, imageHeader)
-		cerr := cerror.NewAndPrintWithTag("UIP02", errors.New(errorMessage), friendlyMessage)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "UIP02", errors.New(errorMessage), friendlyMessage)
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusBadRequest, response)
 		return
 	}
 	/*end validate image*/
 
-	url, err := ih.useCase.SaveImage(c, imageFile, email)
+	url, err := ih.useCase.SaveImage(c.Request.Context(), imageFile, email)
 	if err != nil {
 		response.Message = err.(cerror.Error).FriendlyMessageWithTag()
 		c.JSON(http.StatusInternalServerError, response)
@@ -1,28 +1,32 @@
 package mysql
 
 import (
-	"database/sql"
+	"context"
 	"os"
 	"strings"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/db"
 	"github.com/pajri/personal-backend/domain"
 	"github.com/pajri/personal-backend/global"
 )
 
 type MySqlImageRepository struct {
-	Db *sql.DB
+	Db db.IDB
 }
 
-func NewMySqlImageRepository(db *sql.DB) domain.IImageRepository {
+func NewMySqlImageRepository(database db.IDB) domain.IImageRepository {
 	return &MySqlImageRepository{
-		Db: db,
+		Db: database,
 	}
 }
 
-func (im MySqlImageRepository) SaveImage(image domain.Image) error {
+func (im MySqlImageRepository) SaveImage(ctx context.Context, image domain.Image) error {
+	ctx, cancel := im.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	if image.ImageID == "" {
 		image.ImageID = uuid.New().String()
 	}
@@ -34,93 +38,80 @@ func (im MySqlImageRepository) SaveImage(image domain.Image) error {
 
 	sql, args, err := query.ToSql()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("IMR00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IMR00", err, global.FRIENDLY_MESSAGE)
 	}
 	/*end create query*/
 
 	/*start insert data*/
-	tx, err := im.Db.Begin()
+	tx, err := im.Db.BeginTx(ctx)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("IMR01", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IMR01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sql)
+	stmt, err := tx.PrepareContext(ctx, sql)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("IMR02", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IMR02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sql, args...)
+	_, err = tx.ExecContext(ctx, sql, args...)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("IMR03", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IMR03", err, global.FRIENDLY_MESSAGE)
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("IMR04", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IMR04", err, global.FRIENDLY_MESSAGE)
 	}
 
 	return nil
 	/*end insert data*/
 }
 
-func (im MySqlImageRepository) DeleteImage(image domain.Image, deleteFile bool) error {
-	filter := domain.ImageFilter{ImageURL: image.ImageURL}
-	tx, err := im.deleteFromDb(filter)
-	if err != nil {
-		return err
-	}
+// DeleteImage removes the image row. The file is left in place so the row can still be rolled
+// back, it is removed with DeleteImageFile once the transaction is committed.
+func (im MySqlImageRepository) DeleteImage(ctx context.Context, image domain.Image) error {
+	ctx, cancel := im.Db.WithQueryTimeout(ctx)
+	defer cancel()
 
-	if deleteFile {
-		err = im.deleteFile(image.ImageURL)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
-	}
+	query := sq.Delete("image").
+		Where(sq.Eq{"image_url": image.ImageURL})
 
-	err = tx.Commit()
+	sqlString, args, err := query.ToSql()
 	if err != nil {
-		tx.Rollback()
-		return cerror.NewAndPrintWithTag("DIG00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DIM00", err, global.FRIENDLY_MESSAGE)
 	}
 
-	return nil
-}
-
-func (im MySqlImageRepository) deleteFromDb(filter domain.ImageFilter) (*sql.Tx, error) {
-	query := sq.Delete("image")
-
-	if filter.ImageURL != "" {
-		query = query.Where(sq.Eq{"image_url": filter.ImageURL})
-	}
-
-	sql, args, err := query.ToSql()
+	tx, err := im.Db.BeginTx(ctx)
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("DIM00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DIM01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	tx, err := im.Db.Begin()
+	stmt, err := tx.PrepareContext(ctx, sqlString)
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("DIM01", err, global.FRIENDLY_MESSAGE)
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "DIM02", err, global.FRIENDLY_MESSAGE)
 	}
+	defer stmt.Close()
 
-	stmt, err := tx.Prepare(sql)
+	_, err = tx.ExecContext(ctx, sqlString, args...)
 	if err != nil {
 		tx.Rollback()
-		return nil, cerror.NewAndPrintWithTag("DIM02", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DIM03", err, global.FRIENDLY_MESSAGE)
 	}
-	defer stmt.Close()
 
-	_, err = tx.Exec(sql, args...)
+	err = tx.Commit()
 	if err != nil {
-		tx.Rollback()
-		return nil, cerror.NewAndPrintWithTag("DIM03", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DIM04", err, global.FRIENDLY_MESSAGE)
 	}
 
-	return tx, nil
+	return nil
+}
+
+func (im MySqlImageRepository) DeleteImageFile(image domain.Image) error {
+	return im.deleteFile(image.ImageURL)
 }
 
 func (im MySqlImageRepository) deleteFile(path string) error {
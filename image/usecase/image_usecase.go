@@ -1,40 +1,52 @@
 package usecase
 
 import (
+	"context"
+	"io"
 	"mime/multipart"
+	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/kennygrant/sanitize"
 	"github.com/pajri/personal-backend/adapter/cerror"
 	"github.com/pajri/personal-backend/domain"
 	"github.com/pajri/personal-backend/global"
+	"github.com/pajri/personal-backend/metrics"
 )
 
+// IMAGE_DIR is where uploaded images are stored, relative to the working directory.
+const IMAGE_DIR = "upload/images/"
+
 type ImageUsecase struct {
 	imageRepo domain.IImageRepository
+	postRepo  domain.IPostRepository
+	metrics   *metrics.Metrics
 }
 
-func NewImageUsecase(imageRepository domain.IImageRepository) domain.IImageUsecase {
+func NewImageUsecase(imageRepository domain.IImageRepository,
+	postRepository domain.IPostRepository,
+	_metrics *metrics.Metrics) domain.IImageUsecase {
 	return &ImageUsecase{
 		imageRepo: imageRepository,
+		postRepo:  postRepository,
+		metrics:   _metrics,
 	}
 }
 
-func (iu ImageUsecase) SaveImage(c *gin.Context,
+func (iu ImageUsecase) SaveImage(ctx context.Context,
 	imageFile *multipart.FileHeader, email string) (string, error) {
 	//create filename
 	fileExtension := filepath.Ext(imageFile.Filename)
 	timestamp := time.Now().Format("20060102150405")
 	filename := sanitize.BaseName(email+"_"+timestamp) + fileExtension
-	path := "upload/images/" + filename
+	path := IMAGE_DIR + filename
 
 	//upload file
-	err := c.SaveUploadedFile(imageFile, path)
+	err := iu.saveUploadedFile(imageFile, path)
 	if err != nil {
-		cerror := cerror.NewAndPrintWithTag("UIP00", err, global.FRIENDLY_MESSAGE)
+		cerror := cerror.NewAndPrintWithTagContext(ctx, "UIP00", err, global.FRIENDLY_MESSAGE)
 		return "", cerror
 	}
 
@@ -42,10 +54,77 @@ func (iu ImageUsecase) SaveImage(c *gin.Context,
 	var image domain.Image
 	image.ImageID = uuid.New().String()
 	image.ImageURL = "/" + path
-	err = iu.imageRepo.SaveImage(image)
+	err = iu.imageRepo.SaveImage(ctx, image)
 	if err != nil {
 		return "", err
 	}
+	iu.metrics.ImageUploaded(imageFile.Size)
 
 	return image.ImageURL, nil
 }
+
+// PurgeOrphanImages removes the uploaded images no post shows. Images are uploaded before the post
+// that shows them is created, so only files older than olderThan are considered. It returns the
+// urls of the removed images, or of those that would be removed when dryRun is set.
+func (iu ImageUsecase) PurgeOrphanImages(ctx context.Context, olderThan time.Duration, dryRun bool) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(global.WD, IMAGE_DIR))
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "POI00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	var purged []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return purged, cerror.NewAndPrintWithTagContext(ctx, "POI01", err, global.FRIENDLY_MESSAGE)
+		}
+		if time.Since(info.ModTime()) < olderThan {
+			continue
+		}
+
+		image := domain.Image{ImageURL: "/" + IMAGE_DIR + entry.Name()}
+		isUsed, err := iu.postRepo.IsImageUsed(ctx, image.ImageURL)
+		if err != nil {
+			return purged, err
+		}
+		if isUsed {
+			continue
+		}
+
+		if !dryRun {
+			err = iu.imageRepo.DeleteImage(ctx, image)
+			if err != nil {
+				return purged, err
+			}
+
+			err = iu.imageRepo.DeleteImageFile(image)
+			if err != nil {
+				return purged, err
+			}
+		}
+		purged = append(purged, image.ImageURL)
+	}
+
+	return purged, nil
+}
+
+func (iu ImageUsecase) saveUploadedFile(imageFile *multipart.FileHeader, path string) error {
+	src, err := imageFile.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -4,106 +4,98 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/pajri/personal-backend/adapter/logger"
+	"github.com/pajri/personal-backend/app"
 	"github.com/pajri/personal-backend/config"
-	"github.com/pajri/personal-backend/db"
 	"github.com/pajri/personal-backend/global"
-	"github.com/pajri/personal-backend/helper"
-	"github.com/pajri/personal-backend/middleware"
-
-	_postDelivery "github.com/pajri/personal-backend/post/delivery"
-	_postRepository "github.com/pajri/personal-backend/post/repository/mysql"
-	_postUsecase "github.com/pajri/personal-backend/post/usecase"
-
-	_accountRepository "github.com/pajri/personal-backend/account/repository/mysql"
-	_authDelivery "github.com/pajri/personal-backend/auth/delivery"
-	_authUsecase "github.com/pajri/personal-backend/auth/usecase"
-
-	_profileDelivery "github.com/pajri/personal-backend/profile/delivery"
-	_profileRepository "github.com/pajri/personal-backend/profile/repository/mysql"
-	_profileUsecase "github.com/pajri/personal-backend/profile/usecase"
-
-	_imageDelivery "github.com/pajri/personal-backend/image/delivery"
-	_imageRepository "github.com/pajri/personal-backend/image/repository/mysql"
-	_imageUsecase "github.com/pajri/personal-backend/image/usecase"
 )
 
+const USAGE = `usage : mymoment [command] [flags]
+
+commands :
+  serve                 start the http server (the default)
+  config                print the config after environment overrides
+  migrate               apply, revert or list database migrations
+  create-user           create an account
+  verify-user           mark an account as verified
+  reset-password        set a new password, or email a reset link
+  purge-orphan-images   remove uploaded images no post shows
+  revoke-sessions       sign an account out everywhere
+
+run "mymoment <command> -h" for the flags of a command`
+
+type command func(args []string) error
+
+var commands = map[string]command{
+	"serve":               runServe,
+	"config":              runConfig,
+	"migrate":             runMigrateCommand,
+	"create-user":         runCreateUser,
+	"verify-user":         runVerifyUser,
+	"reset-password":      runResetPassword,
+	"purge-orphan-images": runPurgeOrphanImages,
+	"revoke-sessions":     runRevokeSessions,
+}
+
 func main() {
-	global.InitEnv()
-	env := os.Getenv("PERSONAL_ENV")
-	fmt.Println("environment : " + env)
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	config.InitConfig()
+	if name == "help" {
+		fmt.Println(USAGE)
+		return
+	}
 
-	global.InitWD()
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintln(os.Stderr, USAGE)
+		os.Exit(2)
+	}
 
-	/* start init db*/
-	dbConn, err := db.InitDB()
+	err := run(args)
 	if err != nil {
-		log.Fatal("unable to connect to db : ", err)
+		log.Fatal(name, " : ", err)
 	}
+}
+
+// loadConfig reads the config of the environment in PERSONAL_ENV. Commands call it after parsing
+// their flags, so -h works without a config.
+func loadConfig() (config.Configuration, error) {
+	global.InitEnv()
 
-	err = dbConn.Ping()
+	cfg, fileName, err := config.Load()
 	if err != nil {
-		log.Fatal("error while pinging db : ", err)
+		return config.Configuration{}, err
 	}
 
-	defer func() {
-		err := dbConn.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}()
-	/*end init db*/
-
-	/*start load env variable*/
-	envFileName := global.EnvFileName()
-	err = godotenv.Load(envFileName)
+	err = logger.Init(cfg.Log)
 	if err != nil {
-		log.Fatal("error loading .env file : ", err)
+		return config.Configuration{}, err
 	}
 
-	/*end load env variable*/
-
-	/*start init redis*/
-	helper.InitRedis()
-	defer helper.RedisHelper.(helper.Redis).Client.Close()
-	/*end init redis*/
-
-	r := gin.Default()
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{config.Config.FEHost},
-		AllowMethods:     []string{"GET", "POST"},
-		AllowCredentials: true,
-	}))
-	r.Static("/upload/images/", "./upload/images")
-
-	//setup helper
-	mailHelper := helper.NewEmailHelper()
-
-	//setup repo and usecase
-	imageRepo := _imageRepository.NewMySqlImageRepository(dbConn)
-	imageUsecase := _imageUsecase.NewImageUsecase(imageRepo)
-
-	postRepo := _postRepository.NewMySqlPostRepository(dbConn)
-	postUsecase := _postUsecase.NewPostUseCase(postRepo, imageRepo)
-
-	accountRepo := _accountRepository.NewMySqlAccountRepository(dbConn)
-
-	profileRepo := _profileRepository.NewMySqlProfileRepository(dbConn)
-	profileUsecase := _profileUsecase.NewProfileUsecase(accountRepo, profileRepo)
+	if fileName == "" {
+		logger.Entry().Info("environment : " + global.Env + ", no config file found, using environment variables only")
+	} else {
+		logger.Entry().Info("environment : " + global.Env + ", config : " + fileName)
+	}
 
-	authUsecase := _authUsecase.NewAuthUsecase(accountRepo, profileRepo, mailHelper)
+	for _, key := range cfg.DeprecatedKeys() {
+		logger.Entry().Warnf("config : %s is not used anymore and is ignored, remove it from %s", key, fileName)
+	}
 
-	r.Use(middleware.Middleware(authUsecase))
-	_postDelivery.NewPostHandler(r, postUsecase)
-	_authDelivery.NewAuthHandler(r, authUsecase)
-	_imageDelivery.NewImageHandler(r, imageUsecase)
-	_profileDelivery.NewProfileHandler(r, profileUsecase)
+	global.InitWD()
+	return cfg, nil
+}
 
-	r.Run(":5000")
+func newApp() (*app.App, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
 
+	return app.New(cfg)
 }
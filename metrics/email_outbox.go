@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// EMAIL_OUTBOX_SCRAPE_TIMEOUT bounds the query that counts the outbox on a scrape.
+const EMAIL_OUTBOX_SCRAPE_TIMEOUT = 2 * time.Second
+
+// EmailOutboxStats is what the outbox collector reports. Retrying is the part of Pending that
+// already failed once.
+type EmailOutboxStats struct {
+	Pending         int
+	Retrying        int
+	Sent            int
+	Dead            int
+	OldestPendingAt *time.Time
+}
+
+// emailOutboxCollector counts the emails in the outbox on every scrape.
+type emailOutboxCollector struct {
+	stats func(ctx context.Context) (EmailOutboxStats, error)
+
+	emails           *prometheus.Desc
+	retrying         *prometheus.Desc
+	oldestPendingAge *prometheus.Desc
+}
+
+// RegisterEmailOutbox reports the emails in the outbox by status, read with stats on every
+// scrape.
+func (m *Metrics) RegisterEmailOutbox(stats func(ctx context.Context) (EmailOutboxStats, error)) {
+	if m == nil {
+		return
+	}
+
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "email_outbox", name), help, labels, nil)
+	}
+
+	m.registry.MustRegister(&emailOutboxCollector{
+		stats:            stats,
+		emails:           desc("emails", "Emails in the outbox by status.", "status"),
+		retrying:         desc("retrying_emails", "Pending emails that failed at least once."),
+		oldestPendingAge: desc("oldest_pending_age_seconds", "Age of the oldest pending email, 0 when none is pending."),
+	})
+}
+
+func (c *emailOutboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.emails
+	ch <- c.retrying
+	ch <- c.oldestPendingAge
+}
+
+func (c *emailOutboxCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), EMAIL_OUTBOX_SCRAPE_TIMEOUT)
+	defer cancel()
+
+	stats, err := c.stats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.emails, err)
+		return
+	}
+
+	var oldestPendingAge float64
+	if stats.OldestPendingAt != nil {
+		oldestPendingAge = time.Since(*stats.OldestPendingAt).Seconds()
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.emails, prometheus.GaugeValue, float64(stats.Pending), "pending")
+	ch <- prometheus.MustNewConstMetric(c.emails, prometheus.GaugeValue, float64(stats.Sent), "sent")
+	ch <- prometheus.MustNewConstMetric(c.emails, prometheus.GaugeValue, float64(stats.Dead), "dead")
+	ch <- prometheus.MustNewConstMetric(c.retrying, prometheus.GaugeValue, float64(stats.Retrying))
+	ch <- prometheus.MustNewConstMetric(c.oldestPendingAge, prometheus.GaugeValue, oldestPendingAge)
+}
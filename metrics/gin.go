@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UNMATCHED_ROUTE labels requests no route matched, so random paths do not create new series.
+const UNMATCHED_ROUTE = "unmatched"
+
+// Middleware counts and times every request by its route template, /api/post/:id rather than the
+// path that was requested.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m == nil {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = UNMATCHED_ROUTE
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		m.httpRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		m.httpRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
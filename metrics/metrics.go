@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const NAMESPACE = "mymoment"
+
+const (
+	LOGIN_METHOD_PASSWORD   = "password"
+	LOGIN_METHOD_TOTP       = "totp"
+	LOGIN_METHOD_MAGIC_LINK = "magic_link"
+	LOGIN_METHOD_OIDC       = "oidc"
+
+	LOGIN_RESULT_SUCCESS             = "success"
+	LOGIN_RESULT_FAILURE             = "failure"
+	LOGIN_RESULT_TWO_FACTOR_REQUIRED = "two_factor_required"
+
+	EMAIL_FAILURE_RETRY = "retry"
+	EMAIL_FAILURE_DEAD  = "dead"
+)
+
+// Metrics holds the collectors of one app in their own registry, so apps built in the same
+// process do not collide. Every method works on a nil *Metrics and does nothing, for components
+// built without metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	redisCallDuration   *prometheus.HistogramVec
+	emailsSent          prometheus.Counter
+	emailsFailed        *prometheus.CounterVec
+	imagesUploaded      prometheus.Counter
+	imageUploadBytes    prometheus.Counter
+	logins              *prometheus.CounterVec
+}
+
+// New creates the collectors, together with the go runtime, process and connection pool
+// collectors of dbConn.
+func New(dbConn *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to handle HTTP requests by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		redisCallDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Name:      "redis_call_duration_seconds",
+			Help:      "Time of redis commands by command and result.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"command", "result"}),
+		emailsSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "emails_sent_total",
+			Help:      "Emails handed to the mail transport.",
+		}),
+		emailsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "emails_failed_total",
+			Help:      "Failed email attempts, by whether the email is tried again or dead.",
+		}, []string{"outcome"}),
+		imagesUploaded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "images_uploaded_total",
+			Help:      "Images uploaded.",
+		}),
+		imageUploadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "image_upload_bytes_total",
+			Help:      "Bytes of the images uploaded.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "logins_total",
+			Help:      "Login attempts by method and result.",
+		}, []string{"method", "result"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		newDBStatsCollector(dbConn),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.redisCallDuration,
+		m.emailsSent,
+		m.emailsFailed,
+		m.imagesUploaded,
+		m.imageUploadBytes,
+		m.logins,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveRedisCall(command string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.redisCallDuration.WithLabelValues(command, result).Observe(duration.Seconds())
+}
+
+func (m *Metrics) EmailSent() {
+	if m == nil {
+		return
+	}
+	m.emailsSent.Inc()
+}
+
+// EmailFailed counts a failed attempt, dead tells whether the email is given up.
+func (m *Metrics) EmailFailed(dead bool) {
+	if m == nil {
+		return
+	}
+
+	outcome := EMAIL_FAILURE_RETRY
+	if dead {
+		outcome = EMAIL_FAILURE_DEAD
+	}
+	m.emailsFailed.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) ImageUploaded(size int64) {
+	if m == nil {
+		return
+	}
+	m.imagesUploaded.Inc()
+	m.imageUploadBytes.Add(float64(size))
+}
+
+// ObserveLogin counts a login attempt. A password, magic link or social login of an account with
+// two-factor authentication succeeds with a challenge, which is counted apart.
+func (m *Metrics) ObserveLogin(method string, challenged bool, err error) {
+	if m == nil {
+		return
+	}
+
+	result := LOGIN_RESULT_SUCCESS
+	if err != nil {
+		result = LOGIN_RESULT_FAILURE
+	} else if challenged {
+		result = LOGIN_RESULT_TWO_FACTOR_REQUIRED
+	}
+	m.logins.WithLabelValues(method, result).Inc()
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// redisConn times every command sent with Do.
+type redisConn struct {
+	redis.Conn
+	metrics *Metrics
+}
+
+// InstrumentRedis returns conn with its commands timed.
+func (m *Metrics) InstrumentRedis(conn redis.Conn) redis.Conn {
+	if m == nil {
+		return conn
+	}
+	return redisConn{Conn: conn, metrics: m}
+}
+
+func (rc redisConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	start := time.Now()
+	reply, err := rc.Conn.Do(commandName, args...)
+
+	//a missing key is an answer, not a failed call
+	callErr := err
+	if err == redis.ErrNil {
+		callErr = nil
+	}
+	rc.metrics.ObserveRedisCall(strings.ToUpper(commandName), time.Since(start), callErr)
+
+	return reply, err
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"net/http"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"github.com/pajri/personal-backend/adapter/cerror"
 	"github.com/pajri/personal-backend/domain"
@@ -18,7 +17,7 @@ type AuthResponse struct {
 	Message   string `json:"error_message"`
 }
 
-func handleAuth(c *gin.Context, useCase domain.IAuthUsecase) bool {
+func handleAuth(c *gin.Context, useCase domain.IAuthUsecase, jwtHelper helper.JWTHelper, redisHelper helper.IRedis) bool {
 	if !slice.Contains(excludedFromAuth, c.FullPath()) {
 		var accountID, email string
 
@@ -27,12 +26,11 @@ func handleAuth(c *gin.Context, useCase domain.IAuthUsecase) bool {
 			token := authArr[0]
 
 			/*start parse jwt*/
-			jwtHelper := helper.JWTHelper{}
-			parsedToken, err := jwtHelper.ParseToken(token)
+			claims, err := jwtHelper.ParseAccessToken(token)
 			if err != nil {
 				cerr, ok := err.(cerror.Error)
 				if !ok {
-					cerr = cerror.NewAndPrintWithTag("AUM00", err, global.FRIENDLY_MESSAGE)
+					cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "AUM00", err, global.FRIENDLY_MESSAGE)
 				}
 
 				if cerr.Type != cerror.TYPE_EXPIRED {
@@ -49,8 +47,8 @@ func handleAuth(c *gin.Context, useCase domain.IAuthUsecase) bool {
 				}
 			}
 
-			if parsedToken == nil {
-				cerr := cerror.NewAndPrintWithTag("AUM01", errors.New("parsed token is nil"), global.FRIENDLY_MESSAGE)
+			if claims == nil {
+				cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "AUM01", errors.New("parsed token is nil"), global.FRIENDLY_MESSAGE)
 				resp := AuthResponse{
 					ErrorType: "token_invalid",
 					Message:   cerr.FriendlyMessageWithTag(),
@@ -59,13 +57,12 @@ func handleAuth(c *gin.Context, useCase domain.IAuthUsecase) bool {
 				return false
 			}
 
-			claims := parsedToken.Claims.(jwt.MapClaims)
-			accountID, email = claims["account_id"].(string), claims["email"].(string)
+			accountID, email = claims.AccountID, claims.Email
 			/*end parse jwt*/
 
 			/*start check from redis*/
 			//check if access token exists
-			accessToken, _ := helper.RedisHelper.Get(claims["access_uuid"].(string))
+			accessToken, _ := redisHelper.Get(c.Request.Context(), claims.AccessUUID)
 			if accessToken == "" {
 				//token is expired
 				resp := AuthResponse{ErrorType: "token_expired"}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/stew/slice"
+)
+
+const (
+	MAX_BODY_SIZE = 1 << 20
+	//MAX_UPLOAD_BODY_SIZE leaves room for a 10 MB image and the multipart form around it
+	MAX_UPLOAD_BODY_SIZE = 11 << 20
+)
+
+var uploadRoutes = []string{
+	"/api/image",
+}
+
+// BodyLimit stops reading a request body after MAX_BODY_SIZE bytes, or MAX_UPLOAD_BODY_SIZE on
+// upload routes, so a huge body can not keep a handler busy. Reading past the limit fails like a
+// malformed body.
+func BodyLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := int64(MAX_BODY_SIZE)
+		if slice.Contains(uploadRoutes, c.FullPath()) {
+			limit = MAX_UPLOAD_BODY_SIZE
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
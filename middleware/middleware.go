@@ -3,20 +3,31 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/pajri/personal-backend/domain"
+	"github.com/pajri/personal-backend/helper"
 )
 
 var excludedFromAuth = []string{
 	"/api/auth/login",
+	"/api/auth/login/totp",
+	"/api/auth/magic_link",
+	"/api/auth/magic_link/verify",
+	"/api/auth/oidc/:provider/login",
+	"/api/auth/oidc/:provider/callback",
 	"/api/auth/signup",
 	"/api/auth/verify_email",
+	"/api/auth/resend_verification",
 	"/api/auth/reset_password/",
 	"/api/auth/change_password",
 	"/api/auth/refresh_token",
+	"/.well-known/jwks.json",
+	"/healthz",
+	"/readyz",
+	"/version",
 }
 
-func Middleware(authUseCase domain.IAuthUsecase) gin.HandlerFunc {
+func Middleware(authUseCase domain.IAuthUsecase, jwtHelper helper.JWTHelper, redisHelper helper.IRedis) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		next := handleAuth(c, authUseCase)
+		next := handleAuth(c, authUseCase, jwtHelper, redisHelper)
 		if !next {
 			c.Abort()
 			return
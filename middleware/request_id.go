@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pajri/personal-backend/adapter/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// requestIDPattern is what an id from the client must look like to be kept, anything else could
+// forge log lines.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an id, the one in X-Request-ID when a proxy in front already set
+// it. The id is sent back in the same header and every line logged with the request context has
+// it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(REQUEST_ID_HEADER)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+		c.Header(REQUEST_ID_HEADER, requestID)
+		c.Next()
+	}
+}
+
+// AccessLog logs one line per request when it is done. The path is logged without its query,
+// which can hold tokens.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		entry := logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+			"path":       c.Request.URL.Path,
+			"status":     c.Writer.Status(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":      c.Writer.Size(),
+			"client_ip":  c.ClientIP(),
+		})
+		if accountID := c.GetString("account_id"); accountID != "" {
+			entry = entry.WithField("account_id", accountID)
+		}
+
+		switch status := c.Writer.Status(); {
+		case status >= 500:
+			entry.Error("request")
+		case status >= 400:
+			entry.Warn("request")
+		default:
+			entry.Info("request")
+		}
+	}
+}
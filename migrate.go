@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/pajri/personal-backend/app"
+	"github.com/pajri/personal-backend/db"
+)
+
+const MIGRATE_USAGE = "usage : migrate [up | down [steps] | status]"
+
+func runMigrateCommand(args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	dbConn, err := app.OpenDB(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	return runMigrate(dbConn, args)
+}
+
+// runMigrate handles `migrate up` (the default), `migrate down [steps]` and `migrate status`.
+func runMigrate(dbConn *sql.DB, args []string) error {
+	migrator, err := db.NewMigrator(dbConn)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		if len(args) > 1 {
+			return errors.New(MIGRATE_USAGE)
+		}
+
+		migrations, err := migrator.Up(ctx)
+		printMigrations("applied", migrations)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			return errors.New(MIGRATE_USAGE)
+		}
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New(MIGRATE_USAGE)
+			}
+		}
+
+		migrations, err := migrator.Down(ctx, steps)
+		printMigrations("reverted", migrations)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Up == "" {
+				state += " (unknown to this build)"
+			}
+			fmt.Printf("%04d_%s : %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	}
+
+	return errors.New(MIGRATE_USAGE)
+}
+
+func printMigrations(action string, migrations []db.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("no migration " + action)
+		return
+	}
+
+	for _, migration := range migrations {
+		fmt.Printf("%s %04d_%s\n", action, migration.Version, migration.Name)
+	}
+}
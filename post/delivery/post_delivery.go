@@ -77,7 +77,7 @@ func (ph PostHandler) InsertPost(c *gin.Context) {
 
 	err := c.ShouldBind(&request)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("IPH00", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "IPH00", err, global.FRIENDLY_MESSAGE)
 
 		valError := err.(validator.ValidationErrors)
 		if valError != nil {
@@ -113,7 +113,7 @@ func (ph PostHandler) InsertPost(c *gin.Context) {
 	post.AccountID = accountID
 
 	var storedPost *domain.Post
-	storedPost, err = ph.useCase.InsertPost(post)
+	storedPost, err = ph.useCase.InsertPost(c.Request.Context(), post)
 
 	//the response will be used as first element of listing
 	//so the post response uses PostListingElement type
@@ -140,8 +140,7 @@ func (ph PostHandler) PostListing(c *gin.Context) {
 
 	//validate account id
 	if accountID == "" {
-		fmt.Println("account id is empty")
-		cerr := cerror.NewAndPrintWithTag("PLD00", errors.New("Account id is empty"), global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "PLD00", errors.New("Account id is empty"), global.FRIENDLY_MESSAGE)
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusBadRequest, response)
 		return
@@ -150,13 +149,13 @@ func (ph PostHandler) PostListing(c *gin.Context) {
 	//get request param
 	err := c.ShouldBind(&request)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("PLD01", err, global.FRIENDLY_INVALID_PARAM)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "PLD01", err, global.FRIENDLY_INVALID_PARAM)
 		response.Message = cerr.FriendlyMessageWithTag()
 		c.JSON(http.StatusBadRequest, response)
 		return
 	}
 
-	postList, err := ph.useCase.PostListing(accountID, request.Limit, request.Date)
+	postList, err := ph.useCase.PostListing(c.Request.Context(), accountID, request.Limit, request.Date)
 	if err != nil {
 		response.Message = err.(cerror.Error).FriendlyMessageWithTag()
 		c.JSON(http.StatusInternalServerError, response)
@@ -185,7 +184,7 @@ func (ph PostHandler) DeletePost(c *gin.Context) {
 
 	err := c.ShouldBind(&request)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("DPH00", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "DPH00", err, global.FRIENDLY_MESSAGE)
 
 		valError := err.(validator.ValidationErrors)
 		if valError != nil {
@@ -209,10 +208,17 @@ func (ph PostHandler) DeletePost(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, response)
 	}
 
-	err = ph.useCase.DeletePost(request.PostID, accountID)
+	err = ph.useCase.DeletePost(c.Request.Context(), request.PostID, accountID)
 	if err != nil {
-		response.Message = []string{err.(cerror.Error).FriendlyMessageWithTag()}
-		c.JSON(http.StatusInternalServerError, response)
+		cerr := err.(cerror.Error)
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+
+		httpStatus := http.StatusInternalServerError
+		if cerr.Type == cerror.TYPE_NOT_FOUND {
+			httpStatus = http.StatusNotFound
+		}
+
+		c.JSON(httpStatus, response)
 		return
 	}
 
@@ -1,29 +1,35 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 
 	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/adapter/logger"
+	"github.com/pajri/personal-backend/db"
 	"github.com/pajri/personal-backend/domain"
 	"github.com/pajri/personal-backend/global"
 	"github.com/pajri/personal-backend/util"
 )
 
-func NewMySqlPostRepository(db *sql.DB) domain.IPostRepository {
+func NewMySqlPostRepository(database db.IDB) domain.IPostRepository {
 	return MySqlPostRepository{
-		Db: db,
+		Db: database,
 	}
 }
 
 type MySqlPostRepository struct {
-	Db *sql.DB
+	Db db.IDB
 }
 
-func (ur MySqlPostRepository) InsertPost(post domain.Post) (*domain.Post, error) {
+func (ur MySqlPostRepository) InsertPost(ctx context.Context, post domain.Post) (*domain.Post, error) {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	if post.PostID == "" {
 		post.PostID = util.GenerateUUID()
 	}
@@ -34,40 +40,43 @@ func (ur MySqlPostRepository) InsertPost(post domain.Post) (*domain.Post, error)
 
 	sql, args, err := query.ToSql()
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("IP00", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IP00", err, global.FRIENDLY_MESSAGE)
 	}
 	/*end create query*/
 
 	/*start insert execution*/
-	tx, err := ur.Db.Begin()
+	tx, err := ur.Db.BeginTx(ctx)
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("IP01", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IP01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sql)
+	stmt, err := tx.PrepareContext(ctx, sql)
 	if err != nil {
 		tx.Rollback()
-		return nil, cerror.NewAndPrintWithTag("IP02", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IP02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sql, args...)
+	_, err = tx.ExecContext(ctx, sql, args...)
 	if err != nil {
 		tx.Rollback()
-		return nil, cerror.NewAndPrintWithTag("IP03", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IP03", err, global.FRIENDLY_MESSAGE)
 	}
 
 	err = tx.Commit()
 	if err != nil {
 		tx.Rollback()
-		return nil, cerror.NewAndPrintWithTag("IP04", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "IP04", err, global.FRIENDLY_MESSAGE)
 	}
 
 	return &post, nil
 	/*end insert execution*/
 }
 
-func (ur MySqlPostRepository) PostList(filter domain.PostFilter) ([]domain.Post, error) {
+func (ur MySqlPostRepository) PostList(ctx context.Context, filter domain.PostFilter) ([]domain.Post, error) {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := sq.Select("post_id, content, image_url, date").
 		From("post").
 		OrderBy("date DESC")
@@ -87,16 +96,16 @@ func (ur MySqlPostRepository) PostList(filter domain.PostFilter) ([]domain.Post,
 
 	sql, args, err := query.ToSql()
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("PLI00", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "PLI00", err, global.FRIENDLY_MESSAGE)
 	}
 
-	rows, err := ur.Db.Query(sql, args...)
+	rows, err := ur.Db.QueryContext(ctx, sql, args...)
 	if rows != nil {
 		defer rows.Close()
 	}
 
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("PLI01", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "PLI01", err, global.FRIENDLY_MESSAGE)
 	}
 
 	var postList []domain.Post
@@ -104,7 +113,7 @@ func (ur MySqlPostRepository) PostList(filter domain.PostFilter) ([]domain.Post,
 		var post domain.Post
 		err = rows.Scan(&post.PostID, &post.Content, &post.ImageURL, &post.Date)
 		if err != nil {
-			return nil, cerror.NewAndPrintWithTag("PLI02", err, global.FRIENDLY_MESSAGE)
+			return nil, cerror.NewAndPrintWithTagContext(ctx, "PLI02", err, global.FRIENDLY_MESSAGE)
 		}
 
 		postList = append(postList, post)
@@ -112,13 +121,16 @@ func (ur MySqlPostRepository) PostList(filter domain.PostFilter) ([]domain.Post,
 
 	if err = rows.Close(); err != nil {
 		// but what should we do if there's an error?
-		log.Println(err)
+		logger.WithTag(ctx, "PLI03").Warn(err)
 	}
 
 	return postList, nil
 }
 
-func (ur MySqlPostRepository) GetPost(filter domain.PostFilter) (*domain.Post, error) {
+func (ur MySqlPostRepository) GetPost(ctx context.Context, filter domain.PostFilter) (*domain.Post, error) {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := sq.Select("post_id, content, image_url, date").
 		From("post")
 
@@ -126,26 +138,36 @@ func (ur MySqlPostRepository) GetPost(filter domain.PostFilter) (*domain.Post, e
 		query = query.Where(sq.Eq{"post_id": filter.PostID})
 	}
 
-	sqlString, args, err := query.ToSql()
-	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("GPR00", err, global.FRIENDLY_MESSAGE)
+	if filter.AccountID != "" {
+		query = query.Where(sq.Eq{"account_id": filter.AccountID})
 	}
 
-	row := ur.Db.QueryRow(sqlString, args...)
+	sqlString, args, err := query.ToSql()
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("GPR01", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GPR00", err, global.FRIENDLY_MESSAGE)
 	}
 
+	row := ur.Db.QueryRowContext(ctx, sqlString, args...)
+
 	post := new(domain.Post)
 	err = row.Scan(&post.PostID, &post.Content, &post.ImageURL, &post.Date)
+	if err == sql.ErrNoRows {
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "GPR01", err, global.FRIENDLY_POST_NOT_FOUND)
+		cerr.Type = cerror.TYPE_NOT_FOUND
+		return nil, cerr
+	}
+
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("GPR02", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GPR02", err, global.FRIENDLY_MESSAGE)
 	}
 
 	return post, nil
 }
 
-func (ur MySqlPostRepository) DeletePost(postID, accountID string) error {
+func (ur MySqlPostRepository) DeletePost(ctx context.Context, postID, accountID string) error {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	/*start create query*/
 	query := sq.Delete("post").
 		Where(sq.Eq{
@@ -153,35 +175,136 @@ func (ur MySqlPostRepository) DeletePost(postID, accountID string) error {
 			"account_id": accountID,
 		})
 
-	sql, args, err := query.ToSql()
+	sqlString, args, err := query.ToSql()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("DP00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DP00", err, global.FRIENDLY_MESSAGE)
 	}
 	/*end create query*/
 
-	tx, err := ur.Db.Begin()
+	tx, err := ur.Db.BeginTx(ctx)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("DP01", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DP01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sql)
+	stmt, err := tx.PrepareContext(ctx, sqlString)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("DP02", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DP02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sql, args...)
+	result, err := tx.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "DP03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	affected, err := result.RowsAffected()
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("DP03", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DP05", err, global.FRIENDLY_MESSAGE)
+	}
+
+	if affected == 0 {
+		tx.Rollback()
+		cerr := cerror.NewAndPrintWithTagContext(ctx, "DP06", errors.New("post "+postID+" of "+accountID+" is not found"), global.FRIENDLY_POST_NOT_FOUND)
+		cerr.Type = cerror.TYPE_NOT_FOUND
+		return cerr
 	}
 
 	err = tx.Commit()
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("DP04", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "DP04", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return nil
+}
+
+// DeletePosts removes every post of the account.
+func (ur MySqlPostRepository) DeletePosts(ctx context.Context, accountID string) error {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Delete("post").
+		Where(sq.Eq{"account_id": accountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DPA00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = ur.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DPA01", err, global.FRIENDLY_MESSAGE)
 	}
 
 	return nil
 }
+
+// IsImageUsed reports whether any post shows the image.
+func (ur MySqlPostRepository) IsImageUsed(ctx context.Context, imageURL string) (bool, error) {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("1").
+		From("post").
+		Where(sq.Eq{"image_url": imageURL}).
+		Limit(1)
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "IIU00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	var found int
+	err = ur.Db.QueryRowContext(ctx, sqlString, args...).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, cerror.NewAndPrintWithTagContext(ctx, "IIU01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return true, nil
+}
+
+// GetPostImageURLs returns the image of every post of the account that has one.
+func (ur MySqlPostRepository) GetPostImageURLs(ctx context.Context, accountID string) ([]string, error) {
+	ctx, cancel := ur.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("image_url").
+		From("post").
+		Where(sq.Eq{"account_id": accountID}).
+		Where(sq.NotEq{"image_url": ""})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GPI00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	rows, err := ur.Db.QueryContext(ctx, sqlString, args...)
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GPI01", err, global.FRIENDLY_MESSAGE)
+	}
+	defer rows.Close()
+
+	var imageURLs []string
+	for rows.Next() {
+		var imageURL string
+		err = rows.Scan(&imageURL)
+		if err != nil {
+			return nil, cerror.NewAndPrintWithTagContext(ctx, "GPI02", err, global.FRIENDLY_MESSAGE)
+		}
+		imageURLs = append(imageURLs, imageURL)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GPI03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	return imageURLs, nil
+}
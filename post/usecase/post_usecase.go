@@ -1,28 +1,33 @@
 package usecase
 
 import (
+	"context"
 	"time"
 
+	"github.com/pajri/personal-backend/adapter/logger"
 	"github.com/pajri/personal-backend/domain"
 )
 
 type PostUsecase struct {
-	postRepo  domain.IPostRepository
-	imageRepo domain.IImageRepository
+	postRepo   domain.IPostRepository
+	imageRepo  domain.IImageRepository
+	transactor domain.ITransactor
 }
 
 func NewPostUseCase(postRepository domain.IPostRepository,
-	imageRepository domain.IImageRepository) *PostUsecase {
+	imageRepository domain.IImageRepository,
+	_transactor domain.ITransactor) *PostUsecase {
 
 	return &PostUsecase{
-		postRepo:  postRepository,
-		imageRepo: imageRepository,
+		postRepo:   postRepository,
+		imageRepo:  imageRepository,
+		transactor: _transactor,
 	}
 }
 
-func (uc PostUsecase) InsertPost(post domain.Post) (*domain.Post, error) {
+func (uc PostUsecase) InsertPost(ctx context.Context, post domain.Post) (*domain.Post, error) {
 	post.Date = time.Now()
-	newPost, err := uc.postRepo.InsertPost(post)
+	newPost, err := uc.postRepo.InsertPost(ctx, post)
 	if err != nil {
 		return nil, err
 	}
@@ -30,35 +35,47 @@ func (uc PostUsecase) InsertPost(post domain.Post) (*domain.Post, error) {
 	return newPost, nil
 }
 
-func (uc PostUsecase) PostListing(accountID string, limit uint64, date time.Time) ([]domain.Post, error) {
+func (uc PostUsecase) PostListing(ctx context.Context, accountID string, limit uint64, date time.Time) ([]domain.Post, error) {
 	var postList []domain.Post
 
 	var filter domain.PostFilter
 	filter.AccountID = accountID
 	filter.Limit = limit
 	filter.Date = date
-	postList, err := uc.postRepo.PostList(filter)
+	postList, err := uc.postRepo.PostList(ctx, filter)
 	return postList, err
 }
 
-func (uc PostUsecase) DeletePost(postID, accountID string) error {
-	//get post data
-	postFilter := domain.PostFilter{PostID: postID}
-	post, err := uc.postRepo.GetPost(postFilter)
-	if err != nil {
-		return err
-	}
+func (uc PostUsecase) DeletePost(ctx context.Context, postID, accountID string) error {
+	var post *domain.Post
+	err := uc.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		//get post data
+		postFilter := domain.PostFilter{PostID: postID, AccountID: accountID}
+		var err error
+		post, err = uc.postRepo.GetPost(ctx, postFilter)
+		if err != nil {
+			return err
+		}
 
-	err = uc.postRepo.DeletePost(postID, accountID)
+		err = uc.postRepo.DeletePost(ctx, postID, accountID)
+		if err != nil {
+			return err
+		}
+
+		if post.ImageURL != "" {
+			return uc.imageRepo.DeleteImage(ctx, domain.Image{ImageURL: post.ImageURL})
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
+	//a file cannot be rolled back, so it is only removed once the rows are gone
 	if post.ImageURL != "" {
-		image := domain.Image{ImageURL: post.ImageURL}
-		err = uc.imageRepo.DeleteImage(image, true)
+		err = uc.imageRepo.DeleteImageFile(domain.Image{ImageURL: post.ImageURL})
 		if err != nil {
-			return err
+			logger.WithTag(ctx, "DPU00").WithError(err).Warn("unable to remove image file ", post.ImageURL)
 		}
 	}
 
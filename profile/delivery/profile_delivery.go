@@ -13,19 +13,30 @@ import (
 )
 
 type GetProfileResponse struct {
-	Message  string `json:"message"`
-	FullName string `json:"full_name"`
-	Email    string `json:"email"`
+	Message              string `json:"message"`
+	FullName             string `json:"full_name"`
+	Email                string `json:"email"`
+	NotifySecurityEvents bool   `json:"notify_security_events"`
+	Locale               string `json:"locale"`
 }
 
 type UpdateProfileRequest struct {
 	FullName string `json:"full_name" binding:"required"`
+	Locale   string `json:"locale" binding:"omitempty,max=10"`
 }
 
 type UpdateProfileResponse struct {
 	Message []string `json:"message"`
 }
 
+type UpdateNotificationRequest struct {
+	NotifySecurityEvents *bool `json:"notify_security_events" binding:"required"`
+}
+
+type UpdateNotificationResponse struct {
+	Message []string `json:"message"`
+}
+
 type ProfileHandler struct {
 	useCase domain.IProfileUsecase
 }
@@ -38,6 +49,7 @@ func NewProfileHandler(router *gin.Engine,
 
 	router.GET("/api/profile", handler.GetProfile)
 	router.POST("/api/profile/update", handler.UpdateProfile)
+	router.POST("/api/profile/notification", handler.UpdateNotification)
 }
 
 func (ph ProfileHandler) GetProfile(c *gin.Context) {
@@ -47,12 +59,12 @@ func (ph ProfileHandler) GetProfile(c *gin.Context) {
 	)
 
 	profileInput := domain.Profile{AccountID: accountID}
-	profile, err := ph.useCase.GetProfile(profileInput)
+	profile, err := ph.useCase.GetProfile(c.Request.Context(), profileInput)
 	if err != nil {
 		var cerr cerror.Error
 		cerr, ok := err.(cerror.Error)
 		if !ok {
-			cerr = cerror.NewAndPrintWithTag("GPH00", err, global.FRIENDLY_MESSAGE)
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "GPH00", err, global.FRIENDLY_MESSAGE)
 		}
 
 		response.Message = cerr.FriendlyMessageWithTag()
@@ -62,6 +74,8 @@ func (ph ProfileHandler) GetProfile(c *gin.Context) {
 
 	response.FullName = profile.FullName
 	response.Email = profile.Account.Email
+	response.NotifySecurityEvents = profile.NotifySecurityEvents
+	response.Locale = profile.Locale
 	c.JSON(http.StatusOK, response)
 	return
 }
@@ -76,7 +90,7 @@ func (ph ProfileHandler) UpdateProfile(c *gin.Context) {
 	//validate input
 	err := c.ShouldBind(&request)
 	if err != nil {
-		cerr := cerror.NewAndPrintWithTag("UPH00", err, global.FRIENDLY_MESSAGE)
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "UPH00", err, global.FRIENDLY_MESSAGE)
 
 		/*start validation*/
 		valError := err.(validator.ValidationErrors)
@@ -91,6 +105,10 @@ func (ph ProfileHandler) UpdateProfile(c *gin.Context) {
 					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
 					response.Message = append(response.Message, msg)
 					break
+				case "max":
+					msg := fmt.Sprintf(global.ERR_MAX_CHAR, jsonField, elem.Param())
+					response.Message = append(response.Message, msg)
+					break
 				}
 
 				c.JSON(http.StatusBadRequest, response)
@@ -108,13 +126,14 @@ func (ph ProfileHandler) UpdateProfile(c *gin.Context) {
 	var profile domain.Profile
 	profile.AccountID = accountID
 	profile.FullName = request.FullName
+	profile.Locale = request.Locale
 
 	//update profile
-	err = ph.useCase.UpdateProfile(profile)
+	err = ph.useCase.UpdateProfile(c.Request.Context(), profile)
 	if err != nil {
 		cerr, ok := err.(cerror.Error)
 		if !ok {
-			cerr = cerror.NewAndPrintWithTag("UPH01", err, global.FRIENDLY_MESSAGE)
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "UPH01", err, global.FRIENDLY_MESSAGE)
 		}
 
 		msg := cerr.FriendlyMessageWithTag()
@@ -127,3 +146,60 @@ func (ph ProfileHandler) UpdateProfile(c *gin.Context) {
 	return
 
 }
+
+func (ph ProfileHandler) UpdateNotification(c *gin.Context) {
+	var (
+		request   UpdateNotificationRequest
+		response  UpdateNotificationResponse
+		accountID string = c.GetString("account_id")
+	)
+
+	err := c.ShouldBind(&request)
+	if err != nil {
+		cerr := cerror.NewAndPrintWithTagContext(c.Request.Context(), "UNH00", err, global.FRIENDLY_MESSAGE)
+
+		/*start validation*/
+		valError, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, elem := range valError {
+				fieldName := elem.Field()
+				field, _ := reflect.TypeOf(&request).Elem().FieldByName(fieldName)
+				jsonField, _ := field.Tag.Lookup("json")
+
+				switch elem.Tag() {
+				case "required":
+					msg := fmt.Sprintf(global.ERR_REQUIRED_FORMATTER, jsonField)
+					response.Message = append(response.Message, msg)
+					break
+				}
+			}
+
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		/*end validation*/
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	var profile domain.Profile
+	profile.AccountID = accountID
+	profile.NotifySecurityEvents = *request.NotifySecurityEvents
+
+	err = ph.useCase.UpdateNotificationPreference(c.Request.Context(), profile)
+	if err != nil {
+		cerr, ok := err.(cerror.Error)
+		if !ok {
+			cerr = cerror.NewAndPrintWithTagContext(c.Request.Context(), "UNH01", err, global.FRIENDLY_MESSAGE)
+		}
+
+		response.Message = []string{cerr.FriendlyMessageWithTag()}
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, response)
+	return
+}
@@ -1,67 +1,78 @@
 package mysql
 
 import (
-	"database/sql"
+	"context"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/pajri/personal-backend/adapter/cerror"
+	"github.com/pajri/personal-backend/db"
 	"github.com/pajri/personal-backend/domain"
 	"github.com/pajri/personal-backend/global"
 	"github.com/pajri/personal-backend/util"
 )
 
 type MySqlProfileRepository struct {
-	Db *sql.DB
+	Db db.IDB
 }
 
-func NewMySqlProfileRepository(db *sql.DB) domain.IProfileRepository {
+func NewMySqlProfileRepository(database db.IDB) domain.IProfileRepository {
 	return &MySqlProfileRepository{
-		Db: db,
+		Db: database,
 	}
 }
 
-func (pr MySqlProfileRepository) InsertProfile(profile domain.Profile) error {
+func (pr MySqlProfileRepository) InsertProfile(ctx context.Context, profile domain.Profile) error {
+	ctx, cancel := pr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if profile.Locale == "" {
+		profile.Locale = global.DEFAULT_LOCALE
+	}
+
 	/*start create sql*/
 	query := sq.Insert("profile").
-		Columns("profile_id, full_name, account_id").
-		Values(util.GenerateUUID(), profile.FullName, profile.AccountID)
+		Columns("profile_id, full_name, account_id, locale").
+		Values(util.GenerateUUID(), profile.FullName, profile.AccountID, profile.Locale)
 
 	sql, args, err := query.ToSql()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("IPR00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IPR00", err, global.FRIENDLY_MESSAGE)
 	}
 	/*end create sql*/
 
 	/*start insert data*/
-	tx, err := pr.Db.Begin()
+	tx, err := pr.Db.BeginTx(ctx)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("IPR01", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IPR01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sql)
+	stmt, err := tx.PrepareContext(ctx, sql)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("IPR02", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IPR02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sql, args...)
+	_, err = tx.ExecContext(ctx, sql, args...)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("IPR03", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IPR03", err, global.FRIENDLY_MESSAGE)
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("IA04", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "IA04", err, global.FRIENDLY_MESSAGE)
 	}
 	/*end insert data*/
 
 	return nil
 }
 
-func (pr MySqlProfileRepository) GetProfile(filter domain.ProfileFilter) (*domain.Profile, error) {
-	query := sq.Select("profile_id, full_name").
+func (pr MySqlProfileRepository) GetProfile(ctx context.Context, filter domain.ProfileFilter) (*domain.Profile, error) {
+	ctx, cancel := pr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Select("profile_id, full_name, notify_security_events, locale").
 		From("profile")
 
 	if filter.AccountID != "" {
@@ -70,50 +81,150 @@ func (pr MySqlProfileRepository) GetProfile(filter domain.ProfileFilter) (*domai
 
 	sqlString, args, err := query.ToSql()
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("GPM00", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GPM00", err, global.FRIENDLY_MESSAGE)
 	}
 
-	row := pr.Db.QueryRow(sqlString, args...)
+	row := pr.Db.QueryRowContext(ctx, sqlString, args...)
 	profile := new(domain.Profile)
-	err = row.Scan(&profile.ProfileID, &profile.FullName)
+	err = row.Scan(&profile.ProfileID, &profile.FullName, &profile.NotifySecurityEvents, &profile.Locale)
 	if err != nil {
-		return nil, cerror.NewAndPrintWithTag("GPM01", err, global.FRIENDLY_MESSAGE)
+		return nil, cerror.NewAndPrintWithTagContext(ctx, "GPM01", err, global.FRIENDLY_MESSAGE)
 	}
 
 	return profile, nil
 }
 
-func (pr MySqlProfileRepository) UpdateFullName(profile domain.Profile) error {
+func (pr MySqlProfileRepository) UpdateFullName(ctx context.Context, profile domain.Profile) error {
+	ctx, cancel := pr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := sq.Update("profile").
 		Set("full_name", profile.FullName).
 		Where(sq.Eq{"account_id": profile.AccountID})
 
 	sqlString, args, err := query.ToSql()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UFP00", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UFP00", err, global.FRIENDLY_MESSAGE)
 	}
 
-	tx, err := pr.Db.Begin()
+	tx, err := pr.Db.BeginTx(ctx)
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UFP01", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UFP01", err, global.FRIENDLY_MESSAGE)
 	}
 
-	stmt, err := tx.Prepare(sqlString)
+	stmt, err := tx.PrepareContext(ctx, sqlString)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("UFP02", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UFP02", err, global.FRIENDLY_MESSAGE)
 	}
 	defer stmt.Close()
 
-	_, err = tx.Exec(sqlString, args...)
+	_, err = tx.ExecContext(ctx, sqlString, args...)
 	if err != nil {
 		tx.Rollback()
-		return cerror.NewAndPrintWithTag("UFP03", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UFP03", err, global.FRIENDLY_MESSAGE)
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return cerror.NewAndPrintWithTag("UFP04", err, global.FRIENDLY_MESSAGE)
+		return cerror.NewAndPrintWithTagContext(ctx, "UFP04", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+func (pr MySqlProfileRepository) UpdateNotifySecurityEvents(ctx context.Context, profile domain.Profile) error {
+	ctx, cancel := pr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Update("profile").
+		Set("notify_security_events", profile.NotifySecurityEvents).
+		Where(sq.Eq{"account_id": profile.AccountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "UNS00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	tx, err := pr.Db.BeginTx(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "UNS01", err, global.FRIENDLY_MESSAGE)
 	}
+
+	stmt, err := tx.PrepareContext(ctx, sqlString)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "UNS02", err, global.FRIENDLY_MESSAGE)
+	}
+	defer stmt.Close()
+
+	_, err = tx.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "UNS03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "UNS04", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+func (pr MySqlProfileRepository) UpdateLocale(ctx context.Context, profile domain.Profile) error {
+	ctx, cancel := pr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Update("profile").
+		Set("locale", profile.Locale).
+		Where(sq.Eq{"account_id": profile.AccountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "ULP00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	tx, err := pr.Db.BeginTx(ctx)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "ULP01", err, global.FRIENDLY_MESSAGE)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, sqlString)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "ULP02", err, global.FRIENDLY_MESSAGE)
+	}
+	defer stmt.Close()
+
+	_, err = tx.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		tx.Rollback()
+		return cerror.NewAndPrintWithTagContext(ctx, "ULP03", err, global.FRIENDLY_MESSAGE)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "ULP04", err, global.FRIENDLY_MESSAGE)
+	}
+	return nil
+}
+
+// DeleteProfile removes the profile of the account.
+func (pr MySqlProfileRepository) DeleteProfile(ctx context.Context, accountID string) error {
+	ctx, cancel := pr.Db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := sq.Delete("profile").
+		Where(sq.Eq{"account_id": accountID})
+
+	sqlString, args, err := query.ToSql()
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DPF00", err, global.FRIENDLY_MESSAGE)
+	}
+
+	_, err = pr.Db.ExecContext(ctx, sqlString, args...)
+	if err != nil {
+		return cerror.NewAndPrintWithTagContext(ctx, "DPF01", err, global.FRIENDLY_MESSAGE)
+	}
+
 	return nil
 }
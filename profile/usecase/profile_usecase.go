@@ -1,6 +1,10 @@
 package usecase
 
-import "github.com/pajri/personal-backend/domain"
+import (
+	"context"
+
+	"github.com/pajri/personal-backend/domain"
+)
 
 type ProfileUsecase struct {
 	accountRepo domain.IAccountRepository
@@ -15,11 +19,11 @@ func NewProfileUsecase(accountRepository domain.IAccountRepository,
 	}
 }
 
-func (uc ProfileUsecase) GetProfile(profile domain.Profile) (*domain.Profile, error) {
+func (uc ProfileUsecase) GetProfile(ctx context.Context, profile domain.Profile) (*domain.Profile, error) {
 	//get account
 	var accountFilter domain.AccountFilter
 	accountFilter.AccountID = profile.AccountID
-	storedAccount, err := uc.accountRepo.GetAccount(accountFilter)
+	storedAccount, err := uc.accountRepo.GetAccount(ctx, accountFilter)
 	if err != nil {
 		return nil, err
 	}
@@ -27,7 +31,7 @@ func (uc ProfileUsecase) GetProfile(profile domain.Profile) (*domain.Profile, er
 	//get profile
 	var profileFilter domain.ProfileFilter
 	profileFilter.AccountID = storedAccount.AccountID
-	storedProfile, err := uc.profileRepo.GetProfile(profileFilter)
+	storedProfile, err := uc.profileRepo.GetProfile(ctx, profileFilter)
 	if err != nil {
 		return nil, err
 	}
@@ -35,14 +39,31 @@ func (uc ProfileUsecase) GetProfile(profile domain.Profile) (*domain.Profile, er
 	//populate profile
 	profile.ProfileID = storedProfile.ProfileID
 	profile.FullName = storedProfile.FullName
+	profile.NotifySecurityEvents = storedProfile.NotifySecurityEvents
+	profile.Locale = storedProfile.Locale
 	profile.AccountID = storedAccount.AccountID
 	profile.Account = *storedAccount
 
 	return &profile, nil
 }
 
-func (uc ProfileUsecase) UpdateProfile(profile domain.Profile) error {
-	err := uc.profileRepo.UpdateFullName(profile)
+func (uc ProfileUsecase) UpdateProfile(ctx context.Context, profile domain.Profile) error {
+	err := uc.profileRepo.UpdateFullName(ctx, profile)
+	if err != nil {
+		return err
+	}
+
+	if profile.Locale != "" {
+		err = uc.profileRepo.UpdateLocale(ctx, profile)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (uc ProfileUsecase) UpdateNotificationPreference(ctx context.Context, profile domain.Profile) error {
+	err := uc.profileRepo.UpdateNotifySecurityEvents(ctx, profile)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pajri/personal-backend/adapter/logger"
+)
+
+// runServe starts the http server and the email worker. On SIGINT or SIGTERM the server stops
+// accepting connections, requests in flight and the worker get the shutdown timeout to finish,
+// and then the database and redis are closed.
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "", "address the http server listens on, Server.Addr of the config by default")
+	flags.Parse(args)
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	server := a.HTTPServer()
+	if *addr != "" {
+		server.Addr = *addr
+	}
+
+	stopEmailWorker := make(chan struct{})
+	emailWorkerDone := make(chan struct{})
+	go func() {
+		defer close(emailWorkerDone)
+		a.EmailUsecase.RunWorker(stopEmailWorker)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	serverErr := make(chan error, 2)
+	go func() {
+		logger.Entry().Info("listening on ", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	metricsServer := a.MetricsServer()
+	if metricsServer != nil {
+		go func() {
+			logger.Entry().Info("serving metrics on ", metricsServer.Addr)
+			err := metricsServer.ListenAndServe()
+			if err != http.ErrServerClosed {
+				serverErr <- fmt.Errorf("metrics server : %w", err)
+			}
+		}()
+	}
+
+	select {
+	case err = <-serverErr:
+		//a server did not start, nothing to drain
+	case sig := <-signals:
+		logger.Entry().Infof("received %s, shutting down within %s", sig, a.ShutdownTimeout())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.ShutdownTimeout())
+	defer cancel()
+
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+
+	if err == nil {
+		err = server.Shutdown(ctx)
+		if err != nil {
+			server.Close()
+			err = fmt.Errorf("requests still running after %s were cut off : %w", a.ShutdownTimeout(), err)
+		}
+	} else {
+		server.Close()
+	}
+
+	close(stopEmailWorker)
+	select {
+	case <-emailWorkerDone:
+	case <-ctx.Done():
+		//claimed emails are sent again by another worker once their lease is over
+		logger.Entry().Warn("email worker did not stop in time")
+	}
+
+	if err == nil {
+		logger.Entry().Info("server stopped")
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+package tracing
+
+import (
+	"errors"
+
+	"github.com/pajri/personal-backend/adapter/logger"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// End ends span and marks it failed when err is not nil. The error is redacted the way log lines
+// are, spans leave the app as well.
+func End(span trace.Span, err error) {
+	if err != nil {
+		message := logger.Redact(err.Error())
+		span.RecordError(errors.New(message))
+		span.SetStatus(codes.Error, message)
+	}
+	span.End()
+}
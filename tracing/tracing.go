@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pajri/personal-backend/config"
+	"github.com/pajri/personal-backend/global"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EXPORTER_OTLP   = "otlp"
+	EXPORTER_STDOUT = "stdout"
+
+	DEFAULT_SERVICE_NAME  = "mymoment"
+	DEFAULT_OTLP_ENDPOINT = "localhost:4318"
+
+	// SHUTDOWN_TIMEOUT bounds exporting the spans still buffered when the app stops.
+	SHUTDOWN_TIMEOUT = 5 * time.Second
+
+	// INSTRUMENTATION_NAME names the tracer every span of the app is started with.
+	INSTRUMENTATION_NAME = "github.com/pajri/personal-backend"
+)
+
+// Tracing holds the tracer provider of one app. Every method works on a nil *Tracing, spans are
+// then dropped, so components can be built without tracing.
+type Tracing struct {
+	provider   *sdktrace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// New sets up the exporter of the config. It returns nil when tracing is turned off.
+func New(tracingConfig config.TracingConfig) (*Tracing, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch tracingConfig.Exporter {
+	case "":
+		return nil, nil
+	case EXPORTER_OTLP:
+		exporter, err = newOTLPExporter(tracingConfig)
+	case EXPORTER_STDOUT:
+		//stdout is reserved for the output of commands, the spans go with the logs
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("tracing exporter %s is not supported", tracingConfig.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up tracing exporter : %w", err)
+	}
+
+	serviceName := tracingConfig.ServiceName
+	if serviceName == "" {
+		serviceName = DEFAULT_SERVICE_NAME
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(serviceName),
+		semconv.ServiceVersionKey.String(global.Version),
+		semconv.DeploymentEnvironmentKey.String(global.Env),
+	)
+
+	sampleRatio := tracingConfig.SampleRatio
+	if sampleRatio == 0 {
+		sampleRatio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		//a trace started by a caller is kept or dropped the way the caller decided
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	return &Tracing{
+		provider:   provider,
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}, nil
+}
+
+func newOTLPExporter(tracingConfig config.TracingConfig) (sdktrace.SpanExporter, error) {
+	endpoint := tracingConfig.Endpoint
+	if endpoint == "" {
+		endpoint = DEFAULT_OTLP_ENDPOINT
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if tracingConfig.URLPath != "" {
+		options = append(options, otlptracehttp.WithURLPath(tracingConfig.URLPath))
+	}
+	if tracingConfig.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if len(tracingConfig.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(tracingConfig.Headers))
+	}
+
+	return otlptracehttp.New(context.Background(), options...)
+}
+
+// Tracer returns the tracer components start their spans with.
+func (t *Tracing) Tracer() trace.Tracer {
+	if t == nil {
+		return trace.NewNoopTracerProvider().Tracer(INSTRUMENTATION_NAME)
+	}
+	return t.provider.Tracer(INSTRUMENTATION_NAME, trace.WithInstrumentationVersion(global.Version))
+}
+
+// Shutdown exports the spans that are still buffered.
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.provider.Shutdown(ctx)
+}